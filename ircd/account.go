@@ -0,0 +1,94 @@
+package ircd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+// An Authenticator checks the credentials presented by clients during SASL
+// authentication.  Each method returns the name of the account to which the
+// client is logged in, or false if the credentials are not valid.
+type Authenticator interface {
+	// CheckPassword checks the password of the named account.
+	CheckPassword(name, password string) (account string, ok bool)
+
+	// CheckCertificate checks the fingerprint of a client certificate.  If
+	// the name is not empty, the certificate must belong to that account.
+	CheckCertificate(fingerprint, name string) (account string, ok bool)
+}
+
+// The Authenticator against which SASL authentication is checked when it is
+// not forwarded to services.  It is nil if no accounts are configured.
+var Accounts Authenticator
+
+// An Account is an entry in the accounts file.
+type Account struct {
+	Name     string    `json:"name"`
+	Password *Password `json:"password"`
+	CertFP   []string  `json:"certfps"`
+}
+
+// A FileAuthenticator is an Authenticator backed by a JSON accounts file.
+type FileAuthenticator struct {
+	accounts []*Account
+}
+
+// LoadAccounts reads the accounts file.
+func LoadAccounts(filename string) (*FileAuthenticator, error) {
+	c, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	f := &FileAuthenticator{}
+	if err := json.Unmarshal(c, &f.accounts); err != nil {
+		return nil, err
+	}
+	for _, a := range f.accounts {
+		if len(a.Name) == 0 {
+			return nil, errors.New("account with no name")
+		}
+		if a.Password != nil && !a.Password.Valid() {
+			return nil, errors.New("account " + a.Name + ": invalid password type " + a.Password.Type)
+		}
+	}
+	return f, nil
+}
+
+// find returns the named account, or nil if there is none.
+func (f *FileAuthenticator) find(name string) *Account {
+	for _, a := range f.accounts {
+		if strings.EqualFold(a.Name, name) {
+			return a
+		}
+	}
+	return nil
+}
+
+// CheckPassword implements Authenticator.
+func (f *FileAuthenticator) CheckPassword(name, password string) (string, bool) {
+	a := f.find(name)
+	if a == nil || a.Password == nil || !a.Password.Check(password) {
+		return "", false
+	}
+	return a.Name, true
+}
+
+// CheckCertificate implements Authenticator.
+func (f *FileAuthenticator) CheckCertificate(fingerprint, name string) (string, bool) {
+	if len(fingerprint) == 0 {
+		return "", false
+	}
+	for _, a := range f.accounts {
+		if len(name) > 0 && !strings.EqualFold(a.Name, name) {
+			continue
+		}
+		for _, fp := range a.CertFP {
+			if strings.EqualFold(fp, fingerprint) {
+				return a.Name, true
+			}
+		}
+	}
+	return "", false
+}
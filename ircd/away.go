@@ -0,0 +1,101 @@
+package ircd
+
+var (
+	awayhooks = []*Hook{
+		Register(CMD_AWAY, EMASK_USER, OptArgs(0, 1), Away),
+		Register(CMD_AWAY, EMASK_SERVER, OptArgs(0, 1), SAway),
+	}
+)
+
+// Clients which have enabled away-notify are sent an AWAY when a user who
+// shares a channel with them is marked as away or no longer away.
+var capAwayNotify = RegisterCap("away-notify", "")
+
+// notifyAway tells the local users who share a channel with the user and
+// have enabled away-notify that the user's away message has changed.
+func notifyAway(uid, away string, ircd *IRCd) {
+	notify, _ := splitCapUsers(localPeers(uid), capAwayNotify)
+	if len(notify) == 0 {
+		return
+	}
+	args := []string{}
+	if len(away) > 0 {
+		args = append(args, away)
+	}
+	ircd.ToClient <- &Message{
+		Prefix:  uid,
+		Command: CMD_AWAY,
+		Args:    args,
+		DestIDs: notify,
+	}
+}
+
+// sendAway sends RPL_AWAY to the local user if the target is away.
+func sendAway(uid, target string, ircd *IRCd) {
+	away := GetUser(target).Away()
+	if len(away) == 0 {
+		return
+	}
+	msg := NewNumeric(RPL_AWAY, target).Message(uid)
+	msg.Args[2] = away
+	ircd.ToClient <- msg
+}
+
+// Handle an AWAY from a local client.  Without a message, the user is no
+// longer marked as away.
+//
+//	AWAY [:<message>]
+func Away(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	away := ""
+	if len(msg.Args) > 0 {
+		away = truncate(msg.Args[0], MaxAwayLength)
+	}
+
+	GetUser(uid).SetAway(away)
+	notifyAway(uid, away, ircd)
+	if len(away) > 0 {
+		ircd.ToClient <- NewNumeric(RPL_NOWAWAY).Message(uid)
+	} else {
+		ircd.ToClient <- NewNumeric(RPL_UNAWAY).Message(uid)
+	}
+
+	args := []string{}
+	if len(away) > 0 {
+		args = append(args, away)
+	}
+	for sid := range ServerIter() {
+		ircd.ToServer <- &Message{
+			Prefix:  uid,
+			Command: CMD_AWAY,
+			Args:    args,
+			DestIDs: []string{sid},
+		}
+	}
+}
+
+// Handle an AWAY from a linked server.
+//
+//	:<uid> AWAY [:<message>]
+func SAway(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.Prefix
+	if _, _, _, _, ok := GetUserInfo(uid); !ok {
+		Warn.Printf("AWAY for unknown user %s from %s", uid, msg.SenderID)
+		return
+	}
+
+	away := ""
+	if len(msg.Args) > 0 {
+		away = msg.Args[0]
+	}
+	GetUser(uid).SetAway(away)
+	notifyAway(uid, away, ircd)
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			fmsg := msg.Dup()
+			fmsg.DestIDs = []string{sid}
+			ircd.ToServer <- fmsg
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package ircd
+
+import (
+	"strings"
+	"testing"
+)
+
+var awayTests = []handlerTest{
+	{
+		Desc:   "set away",
+		Hook:   CMD_AWAY,
+		Func:   Away,
+		Sender: "alice",
+		Args:   []string{"Gone fishing"},
+		ToClient: []string{
+			"alice 306 * :You have been marked as being away",
+		},
+		ToServer: []string{
+			"1TA :alice AWAY :Gone fishing",
+			"1TB :alice AWAY :Gone fishing",
+		},
+		Unsorted: true,
+	},
+	{
+		Desc:   "private message to an away user",
+		Hook:   CMD_PRIVMSG,
+		Func:   Privmsg,
+		Sender: "bob",
+		Args:   []string{"alice", "hello"},
+		ToClient: []string{
+			"bob 301 * alice :Gone fishing",
+			"alice @msgid=*;time=* :bob PRIVMSG * hello",
+		},
+	},
+	{
+		Desc:   "notice to an away user",
+		Hook:   CMD_NOTICE,
+		Func:   Privmsg,
+		Sender: "bob",
+		Args:   []string{"alice", "hello"},
+		ToClient: []string{
+			"alice @msgid=*;time=* :bob NOTICE * hello",
+		},
+	},
+	{
+		Desc:   "invite an away user",
+		Hook:   CMD_INVITE,
+		Func:   Invite,
+		Sender: "bob",
+		Args:   []string{"alice", "#away"},
+		ToClient: []string{
+			"bob 341 * #away alice",
+			"bob 301 * alice :Gone fishing",
+			"alice :bob INVITE alice #away",
+		},
+	},
+	{
+		Desc:   "private message from a remote user",
+		Hook:   CMD_PRIVMSG,
+		Func:   Privmsg,
+		Sender: "1TA",
+		Prefix: "carol",
+		Args:   []string{"alice", "hello"},
+		ToClient: []string{
+			"alice @msgid=*;time=* :carol PRIVMSG * hello",
+		},
+	},
+	{
+		Desc:   "unset away",
+		Hook:   CMD_AWAY,
+		Func:   Away,
+		Sender: "alice",
+		ToClient: []string{
+			"alice 305 * :You are no longer marked as being away",
+		},
+		ToServer: []string{
+			"1TA :alice AWAY",
+			"1TB :alice AWAY",
+		},
+		Unsorted: true,
+	},
+	{
+		Desc:   "private message to a user who is back",
+		Hook:   CMD_PRIVMSG,
+		Func:   Privmsg,
+		Sender: "bob",
+		Args:   []string{"alice", "hello"},
+		ToClient: []string{
+			"alice @msgid=*;time=* :bob PRIVMSG * hello",
+		},
+	},
+	{
+		Desc:   "remote user sets away",
+		Hook:   CMD_AWAY,
+		Func:   SAway,
+		Sender: "1TA",
+		Prefix: "carol",
+		Args:   []string{"Lunch"},
+		ToServer: []string{
+			"1TB :carol AWAY Lunch",
+		},
+	},
+}
+
+func TestAway(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	testLink(t, "1TB")
+	testUser(t, Config.SID, "alice")
+	bob := testUser(t, Config.SID, "bob")
+	carol := testUser(t, "1TA", "carol")
+
+	channel, _ := GetChannel("#away", true)
+	channel.Join(bob)
+
+	for _, test := range awayTests {
+		test.run(t)
+	}
+	if got, want := GetUser(carol).Away(), "Lunch"; got != want {
+		t.Errorf("remote away = %q, want %q", got, want)
+	}
+}
+
+func TestBurstAway(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	alice := testUser(t, Config.SID, "alice")
+	testUser(t, Config.SID, "bob")
+	GetUser(alice).SetAway("Gone fishing")
+
+	ircd := testIRCd()
+	Burst(GetServer("1TA", false), ircd)
+	got := []string{}
+	for _, line := range sentLines(ircd.ToServer) {
+		if strings.Contains(line, " AWAY ") {
+			got = append(got, line)
+		}
+	}
+	checkLines(t, "burst", got, []string{
+		"1TA :alice AWAY :Gone fishing",
+	})
+}
@@ -0,0 +1,51 @@
+package ircd
+
+// Clients which have enabled batch are sent related messages (such as the
+// replies to CHATHISTORY) between BATCH +<ref> and BATCH -<ref>, with each
+// message tagged with the batch it belongs to.
+var (
+	capBatch = RegisterCap("batch", "")
+	tagBatch = RegisterTag("batch", capBatch)
+)
+
+// sendBatch sends the messages to the local user in a batch of the given
+// type, with the tags on the BATCH which starts it.  If the user has not
+// enabled batch, the messages are sent alone (without the tags).  Messages
+// which already belong to a batch (such as the BATCH lines of a nested
+// batch) are left in it.
+func sendBatch(uid, typ string, params []string, tags map[string]string, msgs []*Message, ircd *IRCd) {
+	if !GetUser(uid).HasCap(capBatch) {
+		for _, msg := range msgs {
+			msg.DestIDs = []string{uid}
+			ircd.ToClient <- msg
+		}
+		return
+	}
+
+	ref := randomID(6)
+	ircd.ToClient <- &Message{
+		Tags:    tags,
+		Command: CMD_BATCH,
+		Args:    append([]string{"+" + ref, typ}, params...),
+		DestIDs: []string{uid},
+	}
+	for _, msg := range msgs {
+		if _, ok := msg.Tags[tagBatch]; !ok {
+			tags := make(map[string]string, len(msg.Tags)+1)
+			for name, value := range msg.Tags {
+				tags[name] = value
+			}
+			tags[tagBatch] = ref
+			msg.Tags = tags
+		}
+		msg.DestIDs = []string{uid}
+		ircd.ToClient <- msg
+	}
+	ircd.ToClient <- &Message{
+		Command: CMD_BATCH,
+		Args: []string{
+			"-" + ref,
+		},
+		DestIDs: []string{uid},
+	}
+}
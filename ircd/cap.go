@@ -0,0 +1,214 @@
+package ircd
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	caphooks = []*Hook{
+		Register(CMD_CAP, EMASK_REGISTRATION|EMASK_USER, MinArgs(1), Cap).InOrder(),
+	}
+)
+
+// The client capabilities which may be enabled with CAP REQ.
+var (
+	capMutex     = new(sync.RWMutex)
+	capabilities = make(map[string]string) // capabilities[name] = value
+)
+
+// Clients which support cap-notify are sent CAP NEW and CAP DEL when
+// capabilities are added and removed.  It is enabled implicitly for clients
+// which send CAP LS 302.
+var capNotify = RegisterCap("cap-notify", "")
+
+// RegisterCap makes a client capability available and returns its name.  If
+// the value is not empty, it is advertised to clients which send CAP LS 302.
+// It is intended to be called during initialization; use AddCap to add a
+// capability while clients are connected.
+func RegisterCap(name, value string) string {
+	capMutex.Lock()
+	defer capMutex.Unlock()
+	capabilities[name] = value
+	return name
+}
+
+// AddCap makes a client capability available and notifies the local clients
+// which support cap-notify.
+func AddCap(name, value string, ircd *IRCd) {
+	RegisterCap(name, value)
+	for _, uid := range localCapUsers(capNotify) {
+		token := name
+		if len(value) > 0 && GetUser(uid).CapVersion() >= 302 {
+			token += "=" + value
+		}
+		ircd.ToClient <- capMessage(uid, "NEW", token)
+	}
+}
+
+// DelCap makes a client capability unavailable, disables it for every user,
+// and notifies the local clients which support cap-notify.
+func DelCap(name string, ircd *IRCd) {
+	capMutex.Lock()
+	delete(capabilities, name)
+	capMutex.Unlock()
+
+	notify := localCapUsers(capNotify)
+	for _, uid := range localCapUsers(name) {
+		GetUser(uid).SetCaps(nil, []string{name})
+	}
+	for _, uid := range notify {
+		ircd.ToClient <- capMessage(uid, "DEL", name)
+	}
+}
+
+// capTokens returns the available capabilities as advertised in CAP LS with
+// the given version.
+func capTokens(version int) []string {
+	capMutex.RLock()
+	defer capMutex.RUnlock()
+	tokens := make([]string, 0, len(capabilities))
+	for name, value := range capabilities {
+		if len(value) > 0 && version >= 302 {
+			name += "=" + value
+		}
+		tokens = append(tokens, name)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// parseCapReq parses the capabilities requested in a CAP REQ.  Capabilities
+// prefixed with - are to be disabled.  If any of the capabilities is not
+// available, ok is false.
+func parseCapReq(req string) (enable, disable []string, ok bool) {
+	capMutex.RLock()
+	defer capMutex.RUnlock()
+	for _, name := range strings.Fields(req) {
+		list := &enable
+		if strings.HasPrefix(name, "-") {
+			name, list = name[1:], &disable
+		}
+		if _, ok := capabilities[name]; !ok {
+			return nil, nil, false
+		}
+		*list = append(*list, name)
+	}
+	return enable, disable, len(enable)+len(disable) > 0
+}
+
+// splitCapUsers splits the user IDs into those of the users who have enabled
+// the capability and those who have not.
+func splitCapUsers(ids []string, name string) (with, without []string) {
+	for _, id := range ids {
+		if GetUser(id).HasCap(name) {
+			with = append(with, id)
+		} else {
+			without = append(without, id)
+		}
+	}
+	return
+}
+
+// localCapUsers returns the IDs of the local users who have enabled the
+// capability.
+func localCapUsers(name string) []string {
+	ids := []string{}
+	for uid := range UserIter() {
+		if uid[:3] == Config.SID {
+			ids = append(ids, uid)
+		}
+	}
+	with, _ := splitCapUsers(ids, name)
+	return with
+}
+
+// capMessage returns a CAP message to the local user.
+func capMessage(uid, subcommand string, args ...string) *Message {
+	return &Message{
+		Command: CMD_CAP,
+		Args:    append([]string{"*", subcommand}, args...),
+		DestIDs: []string{uid},
+	}
+}
+
+// sendCapList sends a list of capabilities to the local user, split across
+// as many lines as are needed.  Clients which sent CAP LS 302 are told which
+// lines are to be continued.
+func sendCapList(uid, subcommand string, tokens []string, ircd *IRCd) {
+	u := GetUser(uid)
+	// :<server> CAP <nick> <subcommand> * :<tokens>\r\n
+	space := MaxLineLength - (1 + len(Config.Name) + 1 + len(CMD_CAP) + 1 +
+		len(u.Nick()) + 1 + len(subcommand) + 3 + 2 + 2)
+	lines := joinNames(tokens, space)
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	for i, line := range lines {
+		if i < len(lines)-1 && u.CapVersion() >= 302 {
+			ircd.ToClient <- capMessage(uid, subcommand, "*", line)
+			continue
+		}
+		ircd.ToClient <- capMessage(uid, subcommand, line)
+	}
+}
+
+// Handle a CAP from a local client.  If the client starts negotiating before
+// it has registered, registration is held until it sends CAP END.  A client's
+// CAP messages are handled in the order in which they were sent.
+//
+//	CAP LS [<version>]
+//	CAP LIST
+//	CAP REQ :[-]<capability> [[-]<capability>...]
+//	CAP END
+func Cap(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	u := GetUser(uid)
+	_, _, _, typ := u.Info()
+	registered := typ == RegisteredAsUser
+
+	switch sub := strings.ToUpper(msg.Args[0]); sub {
+	case "LS":
+		if !registered {
+			u.SetNegotiating(true)
+		}
+		if len(msg.Args) > 1 {
+			version, _ := strconv.Atoi(msg.Args[1])
+			u.SetCapVersion(version)
+		}
+		if u.CapVersion() >= 302 {
+			u.SetCaps([]string{capNotify}, nil)
+		}
+		sendCapList(uid, sub, capTokens(u.CapVersion()), ircd)
+	case "LIST":
+		sendCapList(uid, sub, u.Caps(), ircd)
+	case "REQ":
+		if !registered {
+			u.SetNegotiating(true)
+		}
+		req := ""
+		if len(msg.Args) > 1 {
+			req = msg.Args[1]
+		}
+		enable, disable, ok := parseCapReq(req)
+		if !ok {
+			ircd.ToClient <- capMessage(uid, "NAK", req)
+			return
+		}
+		u.SetCaps(enable, disable)
+		ircd.ToClient <- capMessage(uid, "ACK", req)
+	case "END":
+		if registered {
+			return
+		}
+		if u.saslSession() != nil {
+			abortSASL(u, ircd)
+		}
+		u.SetNegotiating(false)
+		registerUser(u, ircd)
+	default:
+		ircd.ToClient <- NewNumeric(ERR_INVALIDCAPCMD, msg.Args[0]).Message(uid)
+	}
+}
@@ -0,0 +1,232 @@
+package ircd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var capReqTests = []struct {
+	Req     string
+	Enable  string
+	Disable string
+	OK      bool
+}{
+	{"cap-notify", "cap-notify", "", true},
+	{"cap-notify example.org/test", "cap-notify example.org/test", "", true},
+	{"-cap-notify example.org/test", "example.org/test", "cap-notify", true},
+	{"cap-notify bogus", "", "", false},
+	{"", "", "", false},
+}
+
+func TestCapReq(t *testing.T) {
+	RegisterCap("example.org/test", "value")
+	defer func() {
+		capMutex.Lock()
+		delete(capabilities, "example.org/test")
+		capMutex.Unlock()
+	}()
+
+	for _, version := range []int{0, 302} {
+		want := "example.org/test"
+		if version >= 302 {
+			want += "=value"
+		}
+		found := false
+		for _, token := range capTokens(version) {
+			found = found || token == want
+		}
+		if !found {
+			t.Errorf("capTokens(%d) = %q, want %q", version, capTokens(version), want)
+		}
+	}
+
+	for idx, test := range capReqTests {
+		enable, disable, ok := parseCapReq(test.Req)
+		if got, want := ok, test.OK; got != want {
+			t.Errorf("#%d: parseCapReq(%q) ok = %v, want %v", idx, test.Req, got, want)
+		}
+		if got, want := strings.Join(enable, " "), test.Enable; got != want {
+			t.Errorf("#%d: parseCapReq(%q) enable = %q, want %q", idx, test.Req, got, want)
+		}
+		if got, want := strings.Join(disable, " "), test.Disable; got != want {
+			t.Errorf("#%d: parseCapReq(%q) disable = %q, want %q", idx, test.Req, got, want)
+		}
+	}
+}
+
+func TestCapInOrder(t *testing.T) {
+	testConfig(t)
+	uid := NextUserID()
+	u := GetUser(uid)
+	defer Delete(uid)
+	u.SetNick("Capper")
+	u.SetUser("capper", "Capping User")
+
+	ircd := testIRCd()
+	for _, args := range [][]string{{"LS", "302"}, {"REQ", "cap-notify"}, {"END"}} {
+		DispatchClient(&Message{
+			Command:  CMD_CAP,
+			Args:     args,
+			SenderID: uid,
+		}, ircd)
+	}
+	// Wait for the CAP hooks to return
+	u.inOrder(func(string, *Message, *IRCd) {})(CMD_CAP, nil, ircd)
+
+	if _, _, _, typ := u.Info(); typ != RegisteredAsUser {
+		t.Errorf("type after CAP END = %v, want %v", typ, RegisteredAsUser)
+	}
+	if u.Negotiating() {
+		t.Errorf("negotiating after CAP END = true, want false")
+	}
+	lines := sentLines(ircd.ToClient)
+	for i, want := range []string{"Capper CAP * LS :", "Capper CAP * ACK cap-notify", "Capper 001 "} {
+		if i >= len(lines) || !strings.HasPrefix(lines[i], want) {
+			t.Errorf("line %d = %q, want prefix %q", i, lines, want)
+			break
+		}
+	}
+
+	// A late CAP LS does not hold up registration again
+	u.SetNegotiating(true)
+	if u.Negotiating() {
+		t.Errorf("negotiating after late CAP LS = true, want false")
+	}
+}
+
+// The lines vera is sent when a user does something on #caps, with and
+// without the capability enabled.
+var capOutputTests = []struct {
+	Cap     string
+	Test    handlerTest
+	With    []string
+	Without []string
+}{
+	{
+		Cap: capExtendedJoin,
+		Test: handlerTest{
+			Hook:   CMD_JOIN,
+			Func:   Join,
+			Sender: "alice",
+			Args:   []string{"#caps"},
+		},
+		With: []string{
+			"vera :alice JOIN #caps Alice :alice User",
+		},
+		Without: []string{
+			"alice,olive,vera :alice JOIN #caps",
+		},
+	},
+	{
+		Cap: capAwayNotify,
+		Test: handlerTest{
+			Hook:   CMD_JOIN,
+			Func:   Join,
+			Sender: "alice",
+			Args:   []string{"#caps"},
+		},
+		With: []string{
+			"alice,olive,vera :alice JOIN #caps",
+			"vera :alice AWAY Busy",
+		},
+		Without: []string{
+			"alice,olive,vera :alice JOIN #caps",
+		},
+	},
+	{
+		Cap: capAwayNotify,
+		Test: handlerTest{
+			Hook:   CMD_AWAY,
+			Func:   Away,
+			Sender: "olive",
+			Args:   []string{"Lunch"},
+		},
+		With: []string{
+			"vera :olive AWAY Lunch",
+		},
+	},
+	{
+		Cap: capAccountNotify,
+		Test: handlerTest{
+			Hook:   CMD_ENCAP,
+			Func:   Encap,
+			Sender: "1TA",
+			Prefix: "carol",
+			Args:   []string{"*", "LOGIN", "CarolAcct"},
+		},
+		With: []string{
+			"vera :carol ACCOUNT CarolAcct",
+		},
+	},
+	{
+		Cap: capMultiPrefix,
+		Test: handlerTest{
+			Hook:   CMD_NAMES,
+			Func:   Names,
+			Sender: "vera",
+			Args:   []string{"#caps"},
+		},
+		With: []string{
+			"vera 353 * = #caps :@+olive carol vera",
+			"vera 366 * #caps :End of NAMES list",
+		},
+		Without: []string{
+			"vera 353 * = #caps :@olive carol vera",
+			"vera 366 * #caps :End of NAMES list",
+		},
+	},
+	{
+		Cap: capUserhostInNames,
+		Test: handlerTest{
+			Hook:   CMD_NAMES,
+			Func:   Names,
+			Sender: "vera",
+			Args:   []string{"#caps"},
+		},
+		With: []string{
+			"vera 353 * = #caps :@olive!olive@olive.example carol!carol@carol.example vera!vera@vera.example",
+			"vera 366 * #caps :End of NAMES list",
+		},
+		Without: []string{
+			"vera 353 * = #caps :@olive carol vera",
+			"vera 366 * #caps :End of NAMES list",
+		},
+	},
+}
+
+func TestCapOutput(t *testing.T) {
+	for idx, test := range capOutputTests {
+		for _, enabled := range []bool{false, true} {
+			desc := fmt.Sprintf("#%d %s %s", idx, test.Test.Hook, test.Cap)
+			if !enabled {
+				desc += " disabled"
+			}
+			t.Run(desc, func(t *testing.T) {
+				testConfig(t)
+				testLink(t, "1TA")
+				vera := testUser(t, Config.SID, "vera")
+				olive := testUser(t, Config.SID, "olive")
+				carol := testUser(t, "1TA", "carol")
+				alice := GetUser(testUser(t, Config.SID, "alice"))
+				alice.SetAccount("Alice")
+				alice.SetAway("Busy")
+
+				channel, _ := GetChannel("#caps", true)
+				channel.Join(vera, olive, carol)
+				changes, _ := ParseModeChange([]string{"+ov", olive, olive}, ChannelModes)
+				channel.ApplyModes(changes)
+				if enabled {
+					GetUser(vera).SetCaps([]string{test.Cap}, nil)
+				}
+
+				run := test.Test
+				run.Desc, run.Only, run.ToClient = desc, "vera", test.Without
+				if enabled {
+					run.ToClient = test.With
+				}
+				run.run(t)
+			})
+		}
+	}
+}
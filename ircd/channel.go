@@ -1,7 +1,9 @@
 package ircd
 
 import (
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,6 +14,14 @@ var (
 	chanMap   = make(map[string]*Channel)
 )
 
+var (
+	// The modes set on a channel when a local user creates it.
+	DefaultChannelModes = "+nt"
+
+	// How long an invitation to a channel remains valid.
+	InviteExpiry = time.Hour
+)
+
 // Store the channel information and keep it synchronized across possible
 // multiple accesses.
 type Channel struct {
@@ -19,6 +29,13 @@ type Channel struct {
 	name  string
 	ts    time.Time
 	users map[string]string // users[uid] = hostmask
+	modes ActiveModes       // status modes are stored by uid
+
+	topic   string
+	topicBy string // nick!user@host or server name
+	topicTS time.Time
+
+	invites map[string]time.Time // invites[uid] = expiry
 }
 
 // GetChannel the Channel structure for the given channel.  If it does not exist and
@@ -41,9 +58,12 @@ func GetChannel(name string, create bool) (*Channel, error) {
 	}
 
 	c := &Channel{
-		mutex: new(sync.RWMutex),
-		name:  name,
-		users: make(map[string]string),
+		mutex:   new(sync.RWMutex),
+		name:    name,
+		ts:      time.Now(),
+		users:   make(map[string]string),
+		modes:   make(ActiveModes),
+		invites: make(map[string]time.Time),
 	}
 
 	chanMap[lowname] = c
@@ -59,7 +79,63 @@ func (c *Channel) Name() string {
 func (c *Channel) TS() string {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	return strconv.FormatInt(int64(c.ts.Second()), 10)
+	return strconv.FormatInt(c.ts.Unix(), 10)
+}
+
+// SetTS lowers the channel TS to the given TS if it is older than the
+// current one or if the channel has no members.  It returns true if the TS
+// was changed.
+func (c *Channel) SetTS(ts string) bool {
+	its, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if its >= c.ts.Unix() && len(c.users) > 0 {
+		return false
+	}
+	c.ts = time.Unix(its, 0)
+	return true
+}
+
+// Get the channel topic, who set it, and when (as a string).  If no topic is
+// set, the topic is empty.
+func (c *Channel) Topic() (topic, setter, ts string) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if len(c.topic) == 0 {
+		return "", "", ""
+	}
+	return c.topic, c.topicBy, strconv.FormatInt(c.topicTS.Unix(), 10)
+}
+
+// SetTopic sets the channel topic as of now.  An empty topic unsets it.
+func (c *Channel) SetTopic(topic, setter string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.topic, c.topicBy, c.topicTS = topic, setter, time.Now()
+}
+
+// BurstTopic sets the channel topic from a topic burst.  The topic is only
+// set if the channel has no topic or if the given topic is older and
+// different.  It returns true if the topic was set.
+func (c *Channel) BurstTopic(topic, setter, ts string) bool {
+	its, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(topic) == 0 {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.topic) > 0 && (its >= c.topicTS.Unix() || topic == c.topic) {
+		return false
+	}
+	c.topic, c.topicBy, c.topicTS = topic, setter, time.Unix(its, 0)
+	return true
 }
 
 // Get the chanel member IDs
@@ -79,7 +155,7 @@ func (c *Channel) UserIDsWithPrefix() []string {
 	defer c.mutex.RUnlock()
 	ids := make([]string, 0, len(c.users))
 	for id := range c.users {
-		ids = append(ids, id)
+		ids = append(ids, c.status(id)+id)
 	}
 	return ids
 }
@@ -96,7 +172,30 @@ func (c *Channel) OnChan(uid string) (on bool) {
 func (c *Channel) Join(uids ...string) (notify []string, err error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	return c.join(uids...)
+}
+
+// JoinWithKey joins a local user to the channel if they may join it with the
+// given key (see CanJoin).  The check and the join are made under the same
+// lock, so concurrent joins cannot take the channel past its limit.
+func (c *Channel) JoinWithKey(uid, key string) (notify []string, err error) {
+	hostmask := GetUser(uid).Hostmask()
+	registered := GetUser(uid).HasMode('r')
 
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, on := c.users[uid]; on {
+		return nil, NewNumeric(ERR_USERONCHANNEL, uid, c.name)
+	}
+	if err := c.canJoin(uid, key, hostmask, registered); err != nil {
+		return nil, err
+	}
+	return c.join(uid)
+}
+
+// Make sure the channel mutex is locked before calling this.
+func (c *Channel) join(uids ...string) (notify []string, err error) {
 	for _, uid := range uids {
 		if _, on := c.users[uid]; on {
 			return nil, NewNumeric(ERR_USERONCHANNEL, uid, c.name)
@@ -104,7 +203,7 @@ func (c *Channel) Join(uids ...string) (notify []string, err error) {
 
 		// TODO(kevlar): Check hostmask
 		c.users[uid] = "host@mask"
-		c.ts = time.Now()
+		delete(c.invites, uid)
 	}
 
 	notify = make([]string, 0, len(c.users))
@@ -115,13 +214,227 @@ func (c *Channel) Join(uids ...string) (notify []string, err error) {
 	// Make sure that this channel exists (bad news if it doesn't)
 	chanMutex.Lock()
 	defer chanMutex.Unlock()
-	if _, exist := chanMap[c.name]; !exist {
-		chanMap[c.name] = c
+	if _, exist := chanMap[ToLower(c.name)]; !exist {
+		chanMap[ToLower(c.name)] = c
 	}
 
 	return
 }
 
+// Get the channel modes (without status or list modes) as a mode string
+// followed by any arguments.
+func (c *Channel) Modes() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	modes := make([]Mode, 0, len(c.modes))
+	for _, m := range c.modes {
+		switch m.Spec.Type() {
+		case FlagMode, KeyMode, LimitMode:
+			m.Op = SetMode
+			modes = append(modes, m)
+		}
+	}
+	sort.Sort(modeSort(modes))
+	if len(modes) == 0 {
+		return []string{"+"}
+	}
+	return strings.Fields(ModeString(modes))
+}
+
+// Get the arguments of the given mode (e.g. the list of bans).
+func (c *Channel) ModeArgs(ch rune) []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	_, args := c.modes.Get(ch)
+	return append([]string(nil), args...)
+}
+
+// Get whether the given mode is set on the channel.
+func (c *Channel) HasMode(ch rune) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	isset, _ := c.modes.Get(ch)
+	return isset
+}
+
+// Visible returns whether the channel may be shown to the user.  Secret and
+// private channels are only shown to their members.
+func (c *Channel) Visible(uid string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if _, on := c.users[uid]; on {
+		return true
+	}
+	secret, _ := c.modes.Get('s')
+	private, _ := c.modes.Get('p')
+	return !secret && !private
+}
+
+// Get the channel type shown in RPL_NAMREPLY: @ for secret channels, * for
+// private channels, and = for public channels.
+func (c *Channel) Type() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if secret, _ := c.modes.Get('s'); secret {
+		return "@"
+	}
+	if private, _ := c.modes.Get('p'); private {
+		return "*"
+	}
+	return "="
+}
+
+// ApplyModes applies the mode changes to the channel.  Status modes must
+// have their arguments converted to UIDs.
+func (c *Channel) ApplyModes(changes []Mode) (applied []Mode, errors []error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.modes.Apply(changes)
+}
+
+// ResetModes clears all modes (including status modes) and returns the
+// changes which unset them.
+func (c *Channel) ResetModes() (unset []Mode) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, m := range c.modes {
+		m.Op = UnsetMode
+		if m.Spec.Type() == LimitMode {
+			m.Args = nil
+		}
+		unset = append(unset, m)
+	}
+	c.modes = make(ActiveModes)
+	sort.Sort(modeSort(unset))
+	return
+}
+
+// Get the status prefixes (e.g. "@+") of the given member.
+func (c *Channel) Status(uid string) string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.status(uid)
+}
+
+// Make sure the channel mutex is (r)locked before calling this.
+func (c *Channel) status(uid string) (prefixes string) {
+	for i, ch := range statusMode {
+		if c.modes.Contains(ch, uid) {
+			prefixes += statusPrefix[i : i+1]
+		}
+	}
+	return
+}
+
+// Make sure the channel mutex is locked before calling this.
+func (c *Channel) dropStatus(uid string) {
+	remove := []Mode{}
+	for _, ch := range statusMode {
+		if c.modes.Contains(ch, uid) {
+			remove = append(remove, Mode{
+				Spec: ChannelModes[ch],
+				Op:   UnsetMode,
+				Args: []string{uid},
+			})
+		}
+	}
+	c.modes.Apply(remove)
+}
+
+// Invite records an invitation for the user to join the channel.
+func (c *Channel) Invite(uid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for id, expiry := range c.invites {
+		if now.After(expiry) {
+			delete(c.invites, id)
+		}
+	}
+	c.invites[uid] = now.Add(InviteExpiry)
+}
+
+// Get whether the user has an unexpired invitation to the channel.
+func (c *Channel) Invited(uid string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.invited(uid)
+}
+
+// Make sure the channel mutex is (r)locked before calling this.
+func (c *Channel) invited(uid string) bool {
+	expiry, ok := c.invites[uid]
+	return ok && time.Now().Before(expiry)
+}
+
+// CanJoin checks whether the user may join the channel with the given key.
+// An invitation overrides +i, +k, and +l but not bans.
+func (c *Channel) CanJoin(uid, key string) error {
+	hostmask := GetUser(uid).Hostmask()
+	registered := GetUser(uid).HasMode('r')
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.canJoin(uid, key, hostmask, registered)
+}
+
+// Make sure the channel mutex is (r)locked before calling this.
+func (c *Channel) canJoin(uid, key, hostmask string, registered bool) error {
+	if c.modes.Match('b', hostmask) && !c.modes.Match('e', hostmask) {
+		return NewNumeric(ERR_BANNEDFROMCHAN, c.name)
+	}
+	invited := c.invited(uid)
+	if isset, _ := c.modes.Get('i'); isset && !invited && !c.modes.Match('I', hostmask) {
+		return NewNumeric(ERR_INVITEONLYCHAN, c.name)
+	}
+	if isset, args := c.modes.Get('k'); isset && !invited && args[0] != key {
+		return NewNumeric(ERR_BADCHANNELKEY, c.name)
+	}
+	if isset, args := c.modes.Get('l'); isset && !invited {
+		if limit, _ := strconv.Atoi(args[0]); len(c.users) >= limit {
+			return NewNumeric(ERR_CHANNELISFULL, c.name)
+		}
+	}
+	if isset, _ := c.modes.Get('r'); isset && !registered {
+		return NewNumeric(ERR_NEEDREGGEDNICK, c.name)
+	}
+	return nil
+}
+
+// CanSend checks whether the user may send messages to the channel.
+func (c *Channel) CanSend(uid string) error {
+	hostmask := GetUser(uid).Hostmask()
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if _, on := c.users[uid]; !on {
+		if isset, _ := c.modes.Get('n'); isset {
+			return NewNumeric(ERR_CANNOTSENDTOCHAN, c.name)
+		}
+	}
+	if len(c.status(uid)) > 0 {
+		return nil
+	}
+	if isset, _ := c.modes.Get('m'); isset {
+		return NewNumeric(ERR_CANNOTSENDTOCHAN, c.name)
+	}
+	if c.modes.Match('b', hostmask) && !c.modes.Match('e', hostmask) {
+		return NewNumeric(ERR_CANNOTSENDTOCHAN, c.name)
+	}
+	return nil
+}
+
+// modeSort sorts modes by their mode character.
+type modeSort []Mode
+
+func (s modeSort) Len() int           { return len(s) }
+func (s modeSort) Less(i, j int) bool { return s[i].Spec.Char() < s[j].Spec.Char() }
+func (s modeSort) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 // TODO(kevlar): Eliminate race condition:
 //  - User 1 starts parting #chan
 //  - User 2 gets the *Channel from GetChannel()
@@ -146,13 +459,13 @@ func (c *Channel) Part(uid string) (notify []string, err error) {
 		notify = append(notify, id)
 	}
 	delete(c.users, uid)
-	c.ts = time.Now()
+	c.dropStatus(uid)
 
 	if len(c.users) == 0 {
 		chanMutex.Lock()
 		defer chanMutex.Unlock()
 
-		delete(chanMap, c.name)
+		delete(chanMap, ToLower(c.name))
 	}
 
 	return
@@ -176,10 +489,10 @@ func PartAll(uid string) (notify map[string][]string) {
 			notify[c.name] = append(notify[c.name], id)
 		}
 		delete(c.users, uid)
-		c.ts = time.Now()
+		c.dropStatus(uid)
 
 		if len(c.users) == 0 {
-			delete(chanMap, c.name)
+			delete(chanMap, ToLower(c.name))
 		}
 	}
 
@@ -210,6 +523,7 @@ func ChanSplit(sid string, uids []string) map[string][]string {
 		for leavingUID := range leaving2notify {
 			leavingChanUIDs = append(leavingChanUIDs, leavingUID)
 			delete(c.users, leavingUID)
+			c.dropStatus(leavingUID)
 		}
 		if len(leavingChanUIDs) == 0 {
 			return
@@ -225,7 +539,7 @@ func ChanSplit(sid string, uids []string) map[string][]string {
 		}
 
 		if len(c.users) == 0 {
-			delete(chanMap, c.name)
+			delete(chanMap, ToLower(c.name))
 		}
 	}
 
@@ -242,6 +556,20 @@ func ChanSplit(sid string, uids []string) map[string][]string {
 	return notify
 }
 
+// UserChannels returns the channels of which the user is a member.
+func UserChannels(uid string) []*Channel {
+	chanMutex.RLock()
+	defer chanMutex.RUnlock()
+
+	channels := []*Channel{}
+	for _, c := range chanMap {
+		if c.OnChan(uid) {
+			channels = append(channels, c)
+		}
+	}
+	return channels
+}
+
 func ChannelIter() <-chan string {
 	chanMutex.RLock()
 	defer chanMutex.RUnlock()
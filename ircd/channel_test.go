@@ -3,8 +3,10 @@ package ircd
 import (
 	"fmt"
 	"math/rand"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 var testJoinPart = []struct {
@@ -112,3 +114,191 @@ func BenchmarkJoin(b *testing.B) {
 	}
 	wg.Wait()
 }
+
+var canJoinTests = []struct {
+	Modes string
+	Key   string
+	Error string
+}{
+	{
+		Modes: "+nt",
+	},
+	{
+		Modes: "+b Joiner!*@*",
+		Error: ERR_BANNEDFROMCHAN,
+	},
+	{
+		Modes: "+e *!joiner@*",
+	},
+	{
+		Modes: "+i",
+		Error: ERR_INVITEONLYCHAN,
+	},
+	{
+		Modes: "+I joiner!*@*",
+	},
+	{
+		Modes: "+k secret",
+		Error: ERR_BADCHANNELKEY,
+	},
+	{
+		Modes: "+l 1",
+		Key:   "secret",
+		Error: ERR_CHANNELISFULL,
+	},
+	{
+		Modes: "-l+r",
+		Key:   "secret",
+		Error: ERR_NEEDREGGEDNICK,
+	},
+}
+
+func TestCanJoin(t *testing.T) {
+	op := GetUser(NextUserID())
+	joiner := GetUser(NextUserID())
+	joiner.SetNick("Joiner")
+	defer Delete(joiner.ID())
+	joiner.SetUser("joiner", "Joining User")
+
+	channel, _ := GetChannel("#canjoin", true)
+	channel.Join(op.ID())
+	defer channel.Part(op.ID())
+
+	for idx, test := range canJoinTests {
+		changes, _ := ParseModeChange(strings.Fields(test.Modes), ChannelModes)
+		channel.ApplyModes(changes)
+
+		err := channel.CanJoin(joiner.ID(), test.Key)
+		if num, ok := err.(*Numeric); ok {
+			if got, want := num.num, test.Error; got != want {
+				t.Errorf("#%d: after %q, CanJoin = %s, want %s", idx, test.Modes, got, want)
+			}
+		} else if len(test.Error) > 0 {
+			t.Errorf("#%d: after %q, CanJoin = %v, want %s", idx, test.Modes, err, test.Error)
+		}
+	}
+}
+
+func TestJoinLimit(t *testing.T) {
+	op := NextUserID()
+	channel, _ := GetChannel("#limit", true)
+	channel.Join(op)
+	defer PartAll(op)
+
+	changes, _ := ParseModeChange([]string{"+l", "2"}, ChannelModes)
+	channel.ApplyModes(changes)
+
+	// Only one of the joiners fits, however they race
+	start, joined := make(chan bool), make(chan string)
+	for i := 0; i < 10; i++ {
+		go func(uid string) {
+			<-start
+			if _, err := channel.JoinWithKey(uid, ""); err != nil {
+				uid = ""
+			}
+			joined <- uid
+		}(NextUserID())
+	}
+	close(start)
+	count := 0
+	for i := 0; i < 10; i++ {
+		if uid := <-joined; len(uid) > 0 {
+			defer PartAll(uid)
+			count++
+		}
+	}
+	if got, want := count, 1; got != want {
+		t.Errorf("%d users joined, want %d", got, want)
+	}
+	if got, want := len(channel.UserIDs()), 2; got != want {
+		t.Errorf("%d members, want %d", got, want)
+	}
+}
+
+func TestInvite(t *testing.T) {
+	op := GetUser(NextUserID())
+	invitee := GetUser(NextUserID())
+	invitee.SetNick("Invitee")
+	defer Delete(invitee.ID())
+	invitee.SetUser("invitee", "Invited User")
+
+	channel, _ := GetChannel("#invite", true)
+	channel.Join(op.ID())
+	defer channel.Part(op.ID())
+
+	changes, _ := ParseModeChange([]string{"+ikl", "secret", "1"}, ChannelModes)
+	channel.ApplyModes(changes)
+
+	if err := channel.CanJoin(invitee.ID(), ""); err == nil {
+		t.Errorf("CanJoin before INVITE = nil, want error")
+	}
+
+	channel.Invite(invitee.ID())
+	if err := channel.CanJoin(invitee.ID(), ""); err != nil {
+		t.Errorf("CanJoin after INVITE = %v, want nil", err)
+	}
+
+	changes, _ = ParseModeChange([]string{"+b", "Invitee!*@*"}, ChannelModes)
+	channel.ApplyModes(changes)
+	if err := channel.CanJoin(invitee.ID(), ""); err == nil {
+		t.Errorf("CanJoin when banned = nil, want error")
+	}
+	changes, _ = ParseModeChange([]string{"-b", "Invitee!*@*"}, ChannelModes)
+	channel.ApplyModes(changes)
+
+	channel.Join(invitee.ID())
+	channel.Part(invitee.ID())
+	if channel.Invited(invitee.ID()) {
+		t.Errorf("Invited after JOIN = true, want false")
+	}
+
+	defer func(expiry time.Duration) { InviteExpiry = expiry }(InviteExpiry)
+	InviteExpiry = -time.Second
+	channel.Invite(invitee.ID())
+	if channel.Invited(invitee.ID()) {
+		t.Errorf("Invited after expiry = true, want false")
+	}
+}
+
+func TestBurstTopic(t *testing.T) {
+	c, err := GetChannel("#topic", true)
+	if err != nil {
+		t.Fatalf("GetChannel: %s", err)
+	}
+
+	tests := []struct {
+		Topic, Setter, TS string
+		Set               bool
+		WantTopic         string
+	}{
+		// No topic yet, so any topic is accepted
+		{"first", "a!b@c", "1000", true, "first"},
+		// Newer topics are ignored
+		{"newer", "a!b@c", "2000", false, "first"},
+		// Older identical topics are ignored
+		{"first", "d!e@f", "500", false, "first"},
+		// Older different topics replace the current one
+		{"older", "d!e@f", "500", true, "older"},
+		// Empty topics and bad timestamps are ignored
+		{"", "d!e@f", "100", false, "older"},
+		{"bad", "d!e@f", "bad", false, "older"},
+	}
+
+	for idx, test := range tests {
+		if got, want := c.BurstTopic(test.Topic, test.Setter, test.TS), test.Set; got != want {
+			t.Errorf("#%d: BurstTopic(%q, %q, %q) = %v, want %v", idx, test.Topic, test.Setter, test.TS, got, want)
+		}
+		if got, _, _ := c.Topic(); got != test.WantTopic {
+			t.Errorf("#%d: Topic() = %q, want %q", idx, got, test.WantTopic)
+		}
+	}
+
+	if _, setter, ts := c.Topic(); setter != "d!e@f" || ts != "500" {
+		t.Errorf("Topic() setter, ts = %q, %q, want %q, %q", setter, ts, "d!e@f", "500")
+	}
+
+	c.SetTopic("", "a!b@c")
+	if topic, setter, ts := c.Topic(); topic != "" || setter != "" || ts != "" {
+		t.Errorf("after unset, Topic() = %q, %q, %q, want empty", topic, setter, ts)
+	}
+}
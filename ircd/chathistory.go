@@ -0,0 +1,371 @@
+package ircd
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	historyhooks = []*Hook{
+		Register(CMD_CHATHISTORY, EMASK_USER, MinArgs(1), Chathistory),
+	}
+)
+
+// Clients which have enabled draft/chathistory expect CHATHISTORY to be
+// available (it is available to every client).
+var capChathistory = RegisterCap("draft/chathistory", "")
+
+// The most messages (or targets) sent in reply to one CHATHISTORY.
+const MaxHistoryRequest = 100
+
+// historyIdentity returns the identity with which the user takes part in a
+// private conversation: their account if they are logged in, or else their
+// UID.
+func historyIdentity(uid string) string {
+	if account := GetUser(uid).Account(); len(account) > 0 {
+		return "account:" + ToLower(account)
+	}
+	return "uid:" + uid
+}
+
+// privateHistory returns the items of a private conversation in which the
+// user took part, either as themselves or with their account.
+func privateHistory(uid string, items []*HistoryItem) []*HistoryItem {
+	mine := map[string]bool{"uid:" + uid: true, historyIdentity(uid): true}
+	visible := []*HistoryItem{}
+	for _, item := range items {
+		for _, identity := range item.Participants {
+			if mine[identity] {
+				visible = append(visible, item)
+				break
+			}
+		}
+	}
+	return visible
+}
+
+// recordHistory records a PRIVMSG or NOTICE from the sender in the history
+// of the target.  The name is the channel or nick to which it was sent.  The
+// participants in a private conversation are the UIDs of its two users.
+func recordHistory(target, name, hook, sender, text string, tags map[string]string, participants ...string) {
+	u := GetUser(sender)
+	if account := u.Account(); len(account) > 0 {
+		tags = stampTags(tags)
+		tags[tagAccount] = account
+	}
+	when, err := time.Parse(tagTimeFormat, tags[tagTime])
+	if err != nil {
+		when = time.Now().UTC()
+	}
+	var identities []string
+	for _, uid := range participants {
+		identities = append(identities, historyIdentity(uid))
+	}
+	err = History.Add(target, &HistoryItem{
+		Tags:         tags,
+		Prefix:       u.Hostmask(),
+		Command:      hook,
+		Target:       name,
+		Text:         text,
+		Time:         when,
+		Participants: identities,
+	})
+	if err != nil {
+		Warn.Printf("Recording history of %s: %s", target, err)
+	}
+}
+
+// A historyRef refers to a message (by msgid) or a time in a history.
+type historyRef struct {
+	msgid string
+	time  time.Time
+}
+
+// parseHistoryRef parses a reference to a message or a time.
+//
+//	msgid=<msgid>
+//	timestamp=<time>
+func parseHistoryRef(arg string) (ref historyRef, ok bool) {
+	pieces := strings.SplitN(arg, "=", 2)
+	if len(pieces) != 2 || len(pieces[1]) == 0 {
+		return ref, false
+	}
+	switch pieces[0] {
+	case "msgid":
+		ref.msgid = pieces[1]
+		return ref, true
+	case "timestamp":
+		t, err := time.Parse(time.RFC3339Nano, pieces[1])
+		if err != nil {
+			return ref, false
+		}
+		ref.time = t
+		return ref, true
+	}
+	return ref, false
+}
+
+// before returns the number of items which come before the reference.  If
+// the reference is to a message which is not in the history, ok is false.
+func (r historyRef) before(items []*HistoryItem) (n int, ok bool) {
+	if len(r.msgid) > 0 {
+		for i, item := range items {
+			if item.ID() == r.msgid {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	return sort.Search(len(items), func(i int) bool {
+		return !items[i].Time.Before(r.time)
+	}), true
+}
+
+// after returns the index of the first item which comes after the
+// reference.  If the reference is to a message which is not in the history,
+// ok is false.
+func (r historyRef) after(items []*HistoryItem) (n int, ok bool) {
+	if len(r.msgid) > 0 {
+		n, ok = r.before(items)
+		return n + 1, ok
+	}
+	return sort.Search(len(items), func(i int) bool {
+		return items[i].Time.After(r.time)
+	}), true
+}
+
+// selectHistory returns at most limit of the items (which are oldest first)
+// selected by the CHATHISTORY subcommand and references.  LATEST takes no
+// reference if it was given *.
+func selectHistory(items []*HistoryItem, sub string, refs []historyRef, limit int) []*HistoryItem {
+	first, last := 0, len(items)
+	fromEnd := false // the limit keeps the end of the range
+	ok := true
+
+	switch sub {
+	case "LATEST":
+		if len(refs) > 0 {
+			first, ok = refs[0].after(items)
+		}
+		fromEnd = true
+	case "BEFORE":
+		last, ok = refs[0].before(items)
+		fromEnd = true
+	case "AFTER":
+		first, ok = refs[0].after(items)
+	case "AROUND":
+		var mid int
+		mid, ok = refs[0].before(items)
+		if first = mid - limit/2; first < 0 {
+			first = 0
+		}
+		if first+limit < last {
+			last = first + limit
+		}
+	case "BETWEEN":
+		var ok1, ok2 bool
+		first, ok1 = refs[0].after(items)
+		last, ok2 = refs[1].before(items)
+		if first > last {
+			// The references are in reverse order
+			first, ok1 = refs[1].after(items)
+			last, ok2 = refs[0].before(items)
+			fromEnd = true
+		}
+		ok = ok1 && ok2
+	default:
+		ok = false
+	}
+
+	if !ok || first >= last {
+		return nil
+	}
+	if last-first > limit {
+		if fromEnd {
+			first = last - limit
+		} else {
+			last = first + limit
+		}
+	}
+	return items[first:last]
+}
+
+// historyFor returns the history in which the user may look up the target,
+// and the name by which the target is shown.
+func historyFor(uid, target string) (history, name string, ok bool) {
+	if ValidChannel(target) {
+		channel, err := GetChannel(target, false)
+		if err != nil || !channel.OnChan(uid) {
+			return "", "", false
+		}
+		return historyTarget(channel.Name()), channel.Name(), true
+	}
+	if !ValidNick(target) {
+		return "", "", false
+	}
+	return historyTarget(GetUser(uid).Nick(), target), target, true
+}
+
+// failHistory sends a FAIL reply to a CHATHISTORY.
+func failHistory(uid, code, description string, context []string, ircd *IRCd) {
+	args := append([]string{CMD_CHATHISTORY, code}, context...)
+	ircd.ToClient <- &Message{
+		Command: CMD_FAIL,
+		Args:    append(args, description),
+		DestIDs: []string{uid},
+	}
+}
+
+// Handle a CHATHISTORY from a local client.  The messages are sent in a
+// chathistory batch, oldest first.
+//
+//	CHATHISTORY LATEST <target> <*|msgid=<id>|timestamp=<time>> <limit>
+//	CHATHISTORY BEFORE <target> <msgid=<id>|timestamp=<time>> <limit>
+//	CHATHISTORY AFTER <target> <msgid=<id>|timestamp=<time>> <limit>
+//	CHATHISTORY AROUND <target> <msgid=<id>|timestamp=<time>> <limit>
+//	CHATHISTORY BETWEEN <target> <ref> <ref> <limit>
+//	CHATHISTORY TARGETS <timestamp=<time>> <timestamp=<time>> <limit>
+func Chathistory(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	sub := strings.ToUpper(msg.Args[0])
+
+	nargs := 4
+	switch sub {
+	case "LATEST", "BEFORE", "AFTER", "AROUND", "TARGETS":
+	case "BETWEEN":
+		nargs = 5
+	default:
+		failHistory(uid, "UNKNOWN_COMMAND", "Unknown subcommand", msg.Args[:1], ircd)
+		return
+	}
+	if len(msg.Args) < nargs {
+		failHistory(uid, "NEED_MORE_PARAMS", "Missing parameters", msg.Args[:1], ircd)
+		return
+	}
+
+	limit, err := strconv.Atoi(msg.Args[nargs-1])
+	if err != nil || limit < 0 {
+		failHistory(uid, "INVALID_PARAMS", "Invalid limit", msg.Args[:1], ircd)
+		return
+	}
+	if limit == 0 || limit > MaxHistoryRequest {
+		limit = MaxHistoryRequest
+	}
+
+	// The references follow the target (which TARGETS does not have)
+	refargs := msg.Args[2 : nargs-1]
+	if sub == "TARGETS" {
+		refargs = msg.Args[1 : nargs-1]
+	}
+	refs := []historyRef{}
+	for _, arg := range refargs {
+		if sub == "LATEST" && arg == "*" {
+			continue
+		}
+		ref, ok := parseHistoryRef(arg)
+		if !ok || sub == "TARGETS" && len(ref.msgid) > 0 {
+			failHistory(uid, "INVALID_PARAMS", "Invalid message reference", []string{sub, arg}, ircd)
+			return
+		}
+		refs = append(refs, ref)
+	}
+
+	if sub == "TARGETS" {
+		historyTargets(uid, refs[0].time, refs[1].time, limit, ircd)
+		return
+	}
+
+	target, name, ok := historyFor(uid, msg.Args[1])
+	if !ok {
+		failHistory(uid, "INVALID_TARGET", "Messages could not be retrieved", []string{sub, msg.Args[1]}, ircd)
+		return
+	}
+	items, err := History.Get(target)
+	if err != nil {
+		Warn.Printf("Reading history of %s: %s", target, err)
+		failHistory(uid, "MESSAGE_ERROR", "Messages could not be retrieved", []string{sub, msg.Args[1]}, ircd)
+		return
+	}
+	if !ValidChannel(name) {
+		items = privateHistory(uid, items)
+	}
+
+	msgs := []*Message{}
+	for _, item := range selectHistory(items, sub, refs, limit) {
+		msgs = append(msgs, item.Message())
+	}
+	sendBatch(uid, "chathistory", []string{name}, nil, msgs, ircd)
+}
+
+// historyTargets sends the channels and nicks with which the user has
+// conversations whose latest message was sent between the times.
+func historyTargets(uid string, from, to time.Time, limit int, ircd *IRCd) {
+	type latest struct {
+		name string
+		time time.Time
+	}
+	nick := ToLower(GetUser(uid).Nick())
+	earlier, later := from, to
+	if later.Before(earlier) {
+		earlier, later = later, earlier
+	}
+
+	targets, _ := History.Targets()
+	found := []latest{}
+	for _, target := range targets {
+		var name string
+		private := true
+		switch names := strings.Split(target, " "); {
+		case len(names) == 1:
+			channel, err := GetChannel(names[0], false)
+			if err != nil || !channel.OnChan(uid) {
+				continue
+			}
+			name, private = channel.Name(), false
+		case names[0] == nick:
+			name = names[1]
+		case names[1] == nick:
+			name = names[0]
+		default:
+			continue
+		}
+
+		items, err := History.Get(target)
+		if err == nil && private {
+			items = privateHistory(uid, items)
+		}
+		if err != nil || len(items) == 0 {
+			continue
+		}
+		last := items[len(items)-1].Time
+		if last.After(earlier) && last.Before(later) {
+			found = append(found, latest{name, last})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].time.Before(found[j].time)
+	})
+	if len(found) > limit {
+		if to.Before(from) {
+			found = found[len(found)-limit:]
+		} else {
+			found = found[:limit]
+		}
+	}
+
+	msgs := []*Message{}
+	for _, f := range found {
+		msgs = append(msgs, &Message{
+			Command: CMD_CHATHISTORY,
+			Args: []string{
+				"TARGETS",
+				f.name,
+				"timestamp=" + f.time.UTC().Format(tagTimeFormat),
+			},
+		})
+	}
+	sendBatch(uid, "draft/chathistory-targets", nil, nil, msgs, ircd)
+}
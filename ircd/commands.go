@@ -6,24 +6,47 @@ const (
 	CMD_USER   = "USER"
 	CMD_SERVER = "SERVER"
 	CMD_CAPAB  = "CAPAB"
+	CMD_CAP    = "CAP"
 	CMD_PASS   = "PASS"
 	CMD_ERROR  = "ERROR"
 	CMD_QUIT   = "QUIT"
 	CMD_PING   = "PING"
 	CMD_PONG   = "PONG"
 
+	CMD_AUTHENTICATE = "AUTHENTICATE"
+
 	CMD_OPER = "OPER"
+	CMD_KILL = "KILL"
 	CMD_MODE = "MODE"
 
-	CMD_JOIN  = "JOIN"
-	CMD_PART  = "PART"
-	CMD_WHO   = "WHO"
-	CMD_TOPIC = "TOPIC"
-	CMD_NAMES = "NAMES"
+	CMD_JOIN   = "JOIN"
+	CMD_PART   = "PART"
+	CMD_WHO    = "WHO"
+	CMD_WHOIS  = "WHOIS"
+	CMD_WHOWAS = "WHOWAS"
+	CMD_TOPIC  = "TOPIC"
+	CMD_NAMES  = "NAMES"
+	CMD_LIST   = "LIST"
+	CMD_KICK   = "KICK"
+	CMD_INVITE = "INVITE"
+	CMD_AWAY   = "AWAY"
+
+	CMD_ACCOUNT = "ACCOUNT"
+	CMD_MONITOR = "MONITOR"
+
+	CMD_LUSERS = "LUSERS"
+	CMD_MOTD   = "MOTD"
 
-	CMD_WALLOPS = "WALLOPS"
-	CMD_PRIVMSG = "PRIVMSG"
-	CMD_NOTICE  = "NOTICE"
+	CMD_WALLOPS  = "WALLOPS"
+	CMD_OPERWALL = "OPERWALL"
+	CMD_PRIVMSG  = "PRIVMSG"
+	CMD_NOTICE   = "NOTICE"
+	CMD_TAGMSG   = "TAGMSG"
+	CMD_BATCH    = "BATCH"
+	CMD_FAIL     = "FAIL"
+	CMD_ACK      = "ACK"
+
+	CMD_CHATHISTORY = "CHATHISTORY"
 
 	// Server commands
 	CMD_SJOIN = "SJOIN"
@@ -33,8 +56,14 @@ const (
 	CMD_EUID  = "EUID"
 	CMD_ENCAP = "ENCAP"
 	CMD_BMASK = "BMASK"
+	CMD_TMODE = "TMODE"
 	CMD_TB    = "TB"
 
+	// ENCAP subcommands
+	CMD_LOGIN    = "LOGIN"
+	CMD_SASL     = "SASL"
+	CMD_SVSLOGIN = "SVSLOGIN"
+
 	// Internal commands
 	INT_DELUSER = "deluser" // Delete all UIDs in DestIDs
 )
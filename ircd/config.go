@@ -1,11 +1,16 @@
 package ircd
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"path/filepath"
 	"strconv"
 	"strings"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // a Password stores Passwords for Oper and User directives.
@@ -14,6 +19,36 @@ type Password struct {
 	Password string `json:"pass"`
 }
 
+// The supported password types.
+const (
+	PasswordPlain  = "plain"
+	PasswordBcrypt = "bcrypt"
+	PasswordSHA256 = "sha256-crypt"
+)
+
+// Valid returns true if the password type is supported.
+func (p *Password) Valid() bool {
+	switch p.Type {
+	case PasswordPlain, PasswordBcrypt, PasswordSHA256:
+		return true
+	}
+	return false
+}
+
+// Check returns true if the given plaintext matches the password.
+func (p *Password) Check(plain string) bool {
+	switch p.Type {
+	case PasswordPlain:
+		return subtle.ConstantTimeCompare([]byte(plain), []byte(p.Password)) == 1
+	case PasswordBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(p.Password), []byte(plain)) == nil
+	case PasswordSHA256:
+		hash := SHA256Crypt(plain, p.Password)
+		return len(hash) > 0 && subtle.ConstantTimeCompare([]byte(hash), []byte(p.Password)) == 1
+	}
+	return false
+}
+
 // An Oper is an operator configuration directive.
 type Oper struct {
 	Name     string    `json:"name"`
@@ -22,6 +57,17 @@ type Oper struct {
 	Flag     []string  `json:"flags"`
 }
 
+// MatchHost returns true if the hostname or IP address matches one of the
+// operator's host globs.
+func (o *Oper) MatchHost(host, ip string) bool {
+	return matchHosts(o.Host, host, ip)
+}
+
+// HasFlag returns true if the operator has the given flag.
+func (o *Oper) HasFlag(flag string) bool {
+	return hasFlag(o.Flag, flag)
+}
+
 // A Class is a user/server connection class directive.
 type Class struct {
 	Name string   `json:"name"`
@@ -29,6 +75,43 @@ type Class struct {
 	Flag []string `json:"flags"`
 }
 
+// MatchHost returns true if the hostname or IP address matches one of the
+// class's host globs.
+func (c *Class) MatchHost(host, ip string) bool {
+	return matchHosts(c.Host, host, ip)
+}
+
+// HasFlag returns true if the class has the given flag.
+func (c *Class) HasFlag(flag string) bool {
+	return hasFlag(c.Flag, flag)
+}
+
+// matchHosts returns true if the hostname or IP address matches one of the
+// globs.
+func matchHosts(globs []string, host, ip string) bool {
+	host, ip = strings.ToLower(host), strings.ToLower(ip)
+	for _, glob := range globs {
+		glob = strings.ToLower(glob)
+		if match, _ := filepath.Match(glob, host); match {
+			return true
+		}
+		if match, _ := filepath.Match(glob, ip); match {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFlag returns true if the flag is in the list.
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
 // A Link represents the configuration information for a remote
 // server link.
 type Link struct {
@@ -38,9 +121,16 @@ type Link struct {
 }
 
 // A Ports direcive stores a port range and whether or not it is an SSL port.
+// Clients connect to WebSocket ports with WebSocket (over SSL if it is also
+// an SSL port) instead of raw TCP.
 type Ports struct {
 	SSL        bool   `json:"ssl"`
 	PortString string `json:"port"`
+
+	// WebSocket ports only
+	WebSocket bool     `json:"websocket"`
+	Origins   []string `json:"origins"` // globs; browsers may connect from any origin if empty
+	Proxies   []string `json:"proxies"` // IPs or CIDRs trusted to send X-Forwarded-For
 }
 
 // GetPortList gets the port list specified by the range(s) in this ports directive.
@@ -92,8 +182,26 @@ type Configuration struct {
 	Admin    string   `json:"admin"`
 	Network  *Network `json:"networks"`
 	Ports    []*Ports `json:"ports"`
+	SSLCert  string   `json:"sslcert"`
+	SSLKey   string   `json:"sslkey"`
+	MOTD     string   `json:"motd"`
 	Class    []*Class `json:"classes"`
 	Operator []*Oper  `json:"operators"`
+	Accounts string   `json:"accounts"`
+	Services string   `json:"services"`
+	History  string   `json:"history"`
+	HistLen  int      `json:"historylen"`
+}
+
+// FindClass returns the first class whose hosts match the hostname or IP
+// address, or nil if there is none.
+func (c *Configuration) FindClass(host, ip string) *Class {
+	for _, class := range c.Class {
+		if class.MatchHost(host, ip) {
+			return class
+		}
+	}
+	return nil
 }
 
 func (c *Configuration) Check() (okay bool) {
@@ -117,10 +225,55 @@ func (c *Configuration) Check() (okay bool) {
 		Error.Printf("no operators defined: at least one required")
 		okay = false
 	}
+	for _, oper := range c.Operator {
+		if oper.Password == nil || !oper.Password.Valid() {
+			Error.Printf("operator %q: password type must be one of %q, %q, or %q",
+				oper.Name, PasswordPlain, PasswordBcrypt, PasswordSHA256)
+			okay = false
+		}
+	}
+
+	// Check SSL: SSL ports require a certificate and key
+	for _, ports := range c.Ports {
+		if ports.SSL && (len(c.SSLCert) == 0 || len(c.SSLKey) == 0) {
+			Error.Printf("ssl port %q requires sslcert and sslkey", ports.PortString)
+			okay = false
+		}
+	}
+
+	// Check WebSocket: trusted proxies must be IP addresses or CIDR ranges
+	for _, ports := range c.Ports {
+		if _, err := parseNetworks(ports.Proxies); err != nil {
+			Error.Printf("websocket port %q: %s", ports.PortString, err)
+			okay = false
+		}
+	}
+
+	// Check accounts: the accounts file must be valid
+	if len(c.Accounts) > 0 {
+		if _, err := LoadAccounts(c.Accounts); err != nil {
+			Error.Printf("accounts file %q: %s", c.Accounts, err)
+			okay = false
+		}
+	}
 
 	return
 }
 
+// TLSConfig loads the SSL certificate and key and returns the configuration
+// for SSL ports.  Clients are asked for (but not required to send) a
+// certificate so that its fingerprint can be used to identify them.
+func (c *Configuration) TLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.SSLCert, c.SSLKey)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequestClientCert,
+	}, nil
+}
+
 // A suitable default configuration which an admin should
 // base his ircd.conf.
 var DefaultConfiguration = Configuration{
@@ -152,6 +305,9 @@ var DefaultConfiguration = Configuration{
 			SSL:        true,
 		},
 	},
+	SSLCert: "/etc/ircd.crt",
+	SSLKey:  "/etc/ircd.key",
+	MOTD:    "/etc/ircd.motd",
 	Class: []*Class{
 		&Class{
 			Name: "users",
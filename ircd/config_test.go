@@ -2,6 +2,8 @@ package ircd
 
 import (
 	"testing"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 var portRangeTest = []struct {
@@ -43,3 +45,57 @@ func TestPortRanges(t *testing.T) {
 		}
 	}
 }
+
+func TestPasswordCheck(t *testing.T) {
+	bhash, err := bcrypt.GenerateFromPassword([]byte("blight"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %s", err)
+	}
+
+	tests := []struct {
+		Password *Password
+		Plain    string
+		Match    bool
+	}{
+		{&Password{PasswordPlain, "blight"}, "blight", true},
+		{&Password{PasswordPlain, "blight"}, "Blight", false},
+		{&Password{PasswordBcrypt, string(bhash)}, "blight", true},
+		{&Password{PasswordBcrypt, string(bhash)}, "blight2", false},
+		{&Password{PasswordBcrypt, "blight"}, "blight", false},
+		{&Password{PasswordSHA256, SHA256Crypt("blight", "$5$abcdefgh")}, "blight", true},
+		{&Password{PasswordSHA256, SHA256Crypt("blight", "$5$abcdefgh")}, "blight2", false},
+		{&Password{PasswordSHA256, "blight"}, "blight", false},
+		{&Password{"rot13", "oyvtug"}, "blight", false},
+	}
+
+	for idx, test := range tests {
+		if got, want := test.Password.Check(test.Plain), test.Match; got != want {
+			t.Errorf("#%d: %s.Check(%q) = %v, want %v", idx, test.Password.Type, test.Plain, got, want)
+		}
+	}
+}
+
+func TestOperMatchHost(t *testing.T) {
+	oper := &Oper{
+		Host: []string{
+			"127.0.0.1",
+			"*.google.com",
+		},
+	}
+
+	tests := []struct {
+		Host, IP string
+		Match    bool
+	}{
+		{"localhost", "127.0.0.1", true},
+		{"mail.Google.com", "10.0.0.1", true},
+		{"google.com", "10.0.0.1", false},
+		{"127.0.0.2", "127.0.0.2", false},
+	}
+
+	for idx, test := range tests {
+		if got, want := oper.MatchHost(test.Host, test.IP), test.Match; got != want {
+			t.Errorf("#%d: MatchHost(%q, %q) = %v, want %v", idx, test.Host, test.IP, got, want)
+		}
+	}
+}
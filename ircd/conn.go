@@ -2,8 +2,12 @@ package ircd
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"log"
 	"net"
+	"strings"
 )
 
 type Conn struct {
@@ -13,6 +17,7 @@ type Conn struct {
 	onclose     map[chan<- string]bool
 	Error       error
 	id          string
+	ip          string
 	reading     bool
 }
 
@@ -23,8 +28,9 @@ func NewConn(nc net.Conn) *Conn {
 		subscribers: make(map[chan<- *Message]bool),
 		onclose:     make(map[chan<- string]bool),
 		id:          NextUserID(),
+		ip:          remoteIP(nc),
 	}
-	log.Printf("[%s] ** Connected", c.id)
+	log.Printf("[%s] ** Connected from %s", c.id, c.ip)
 	return c
 }
 
@@ -39,6 +45,68 @@ func (c *Conn) ID() string {
 	return c.id
 }
 
+// remoteIP returns the remote IP address of the connection.  IPv6 addresses
+// beginning with a colon are prefixed with a 0 so that they can be sent as a
+// message parameter.
+func remoteIP(nc net.Conn) string {
+	if nc.RemoteAddr() == nil {
+		return ""
+	}
+	addr := nc.RemoteAddr().String()
+	ip, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		ip = addr
+	}
+	if strings.HasPrefix(ip, ":") {
+		ip = "0" + ip
+	}
+	return ip
+}
+
+// IP returns the remote IP address of the connection.
+func (c *Conn) IP() string {
+	return c.ip
+}
+
+// tlsConn returns the TLS connection underlying the connection, if it is
+// encrypted.
+func (c *Conn) tlsConn() (*tls.Conn, bool) {
+	nc := c.Conn
+	if ws, ok := nc.(*wsConn); ok {
+		nc = ws.Conn
+	}
+	tc, ok := nc.(*tls.Conn)
+	return tc, ok
+}
+
+// WebSocket returns true if the client connected with WebSocket.
+func (c *Conn) WebSocket() bool {
+	_, ok := c.Conn.(*wsConn)
+	return ok
+}
+
+// TLS returns true if the connection is encrypted.
+func (c *Conn) TLS() bool {
+	_, ok := c.tlsConn()
+	return ok
+}
+
+// Fingerprint returns the hex-encoded SHA-256 fingerprint of the client
+// certificate, if the connection is encrypted and the client sent one.  It
+// should not be called before the first message has been read.
+func (c *Conn) Fingerprint() string {
+	tc, ok := c.tlsConn()
+	if !ok {
+		return ""
+	}
+	certs := tc.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(certs[0].Raw)
+	return hex.EncodeToString(sum[:])
+}
+
 func (c *Conn) readthread() {
 	// Always close the connection
 	defer c.Close()
@@ -53,8 +121,8 @@ func (c *Conn) readthread() {
 			return
 		}
 		message := ParseMessage(line)
-		message.SenderID = c.id
 		if message != nil {
+			message.SenderID = c.id
 			for subscriber := range c.subscribers {
 				subscriber <- message
 			}
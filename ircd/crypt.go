@@ -0,0 +1,152 @@
+package ircd
+
+import (
+	"crypto/sha256"
+	"strconv"
+	"strings"
+)
+
+// Parameters for SHA-256 based crypt(3) ("$5$") hashes as described in
+// http://www.akkadia.org/drepper/SHA-crypt.txt
+const (
+	cryptSaltMax       = 16
+	cryptRoundsMin     = 1000
+	cryptRoundsMax     = 999999999
+	cryptRoundsDefault = 5000
+)
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// The order in which bytes of the final digest are encoded (three at a time).
+var cryptPermutation = [][3]int{
+	{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+	{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+}
+
+// SHA256Crypt hashes the password using the salt and number of rounds in the
+// given setting, which is either a full hash or just its "$5$[rounds=N$]salt"
+// prefix.  The result can be compared against the setting if it is a hash.
+func SHA256Crypt(password, setting string) string {
+	if !strings.HasPrefix(setting, "$5$") {
+		return ""
+	}
+	salt := setting[3:]
+
+	rounds, custom := cryptRoundsDefault, false
+	if strings.HasPrefix(salt, "rounds=") {
+		if end := strings.Index(salt, "$"); end >= 0 {
+			if n, err := strconv.Atoi(salt[7:end]); err == nil {
+				rounds, custom = n, true
+				salt = salt[end+1:]
+			}
+		}
+	}
+	if rounds < cryptRoundsMin {
+		rounds = cryptRoundsMin
+	}
+	if rounds > cryptRoundsMax {
+		rounds = cryptRoundsMax
+	}
+
+	if end := strings.Index(salt, "$"); end >= 0 {
+		salt = salt[:end]
+	}
+	if len(salt) > cryptSaltMax {
+		salt = salt[:cryptSaltMax]
+	}
+
+	key, s := []byte(password), []byte(salt)
+
+	// Digest B
+	h := sha256.New()
+	h.Write(key)
+	h.Write(s)
+	h.Write(key)
+	b := h.Sum(nil)
+
+	// Digest A
+	h.Reset()
+	h.Write(key)
+	h.Write(s)
+	i := len(key)
+	for ; i > len(b); i -= len(b) {
+		h.Write(b)
+	}
+	h.Write(b[:i])
+	for i = len(key); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(key)
+		}
+	}
+	a := h.Sum(nil)
+
+	// Byte sequence P
+	h.Reset()
+	for i = 0; i < len(key); i++ {
+		h.Write(key)
+	}
+	p := cryptRepeat(h.Sum(nil), len(key))
+
+	// Byte sequence S
+	h.Reset()
+	for i = 0; i < 16+int(a[0]); i++ {
+		h.Write(s)
+	}
+	ds := cryptRepeat(h.Sum(nil), len(s))
+
+	c := a
+	for i = 0; i < rounds; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(ds)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(nil)
+	}
+
+	out := []byte("$5$")
+	if custom {
+		out = append(out, "rounds="+strconv.Itoa(rounds)+"$"...)
+	}
+	out = append(out, salt...)
+	out = append(out, '$')
+	for _, idx := range cryptPermutation {
+		out = cryptEncode(out, c[idx[0]], c[idx[1]], c[idx[2]], 4)
+	}
+	out = cryptEncode(out, 0, c[31], c[30], 3)
+	return string(out)
+}
+
+// cryptRepeat returns the first n bytes of the digest repeated indefinitely.
+func cryptRepeat(digest []byte, n int) []byte {
+	out := make([]byte, n)
+	for i := 0; i < n; i += len(digest) {
+		copy(out[i:], digest)
+	}
+	return out
+}
+
+// cryptEncode appends n characters encoding the three bytes (least
+// significant first) to out.
+func cryptEncode(out []byte, b2, b1, b0 byte, n int) []byte {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for ; n > 0; n-- {
+		out = append(out, cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package ircd
+
+import (
+	"testing"
+)
+
+// Test vectors from http://www.akkadia.org/drepper/SHA-crypt.txt
+var sha256CryptTests = []struct {
+	Setting  string
+	Password string
+	Hash     string
+}{
+	{
+		Setting:  "$5$saltstring",
+		Password: "Hello world!",
+		Hash:     "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+	},
+	{
+		Setting:  "$5$rounds=10000$saltstringsaltstring",
+		Password: "Hello world!",
+		Hash:     "$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA",
+	},
+	{
+		Setting:  "$5$rounds=5000$toolongsaltstring",
+		Password: "This is just a test",
+		Hash:     "$5$rounds=5000$toolongsaltstrin$Un/5jzAHMgOGZ5.mWJpuVolil07guHPvOW8mGRcvxa5",
+	},
+	{
+		Setting:  "$5$rounds=1400$anotherlongsaltstring",
+		Password: "a very much longer text to encrypt.  This one even stretches over morethan one line.",
+		Hash:     "$5$rounds=1400$anotherlongsalts$Rx.j8H.h8HjEDGomFU8bDkXm3XIUnzyxf12oP84Bnq1",
+	},
+	{
+		Setting:  "$5$rounds=10$roundstoolow",
+		Password: "the minimum number is still observed",
+		Hash:     "$5$rounds=1000$roundstoolow$yfvwcWrQ8l/K0DAWyuPMDNHpIVlTQebY9l/gL972bIC",
+	},
+	{
+		Setting:  "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		Password: "Hello world!",
+		Hash:     "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+	},
+	{
+		Setting:  "$1$saltstring",
+		Password: "Hello world!",
+		Hash:     "",
+	},
+}
+
+func TestSHA256Crypt(t *testing.T) {
+	for idx, test := range sha256CryptTests {
+		if got, want := SHA256Crypt(test.Password, test.Setting), test.Hash; got != want {
+			t.Errorf("#%d: SHA256Crypt(%q, %q) = %q, want %q", idx, test.Password, test.Setting, got, want)
+		}
+	}
+}
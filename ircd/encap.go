@@ -0,0 +1,65 @@
+package ircd
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+var (
+	encaphooks = []*Hook{
+		Register(CMD_ENCAP, EMASK_SERVER, MinArgs(2), Encap),
+	}
+)
+
+// The ENCAP subcommands understood by this server.  Each is called with a
+// message whose command and arguments are those of the subcommand.
+var encapHandlers = map[string]func(msg *Message, ircd *IRCd){
+	CMD_LOGIN:    SLogin,
+	CMD_SASL:     SSASL,
+	CMD_SVSLOGIN: SSvslogin,
+}
+
+// sendEncap sends an ENCAP from this server to the servers matching target.
+func sendEncap(prefix, target, subcommand string, args []string, ircd *IRCd) {
+	for sid := range ServerIter() {
+		ircd.ToServer <- &Message{
+			Prefix:  prefix,
+			Command: CMD_ENCAP,
+			Args:    append([]string{target, subcommand}, args...),
+			DestIDs: []string{sid},
+		}
+	}
+}
+
+// Handle an ENCAP from a linked server.  The message is passed on to the
+// rest of the network, and the subcommand is handled if our name matches
+// the target mask.
+//
+//	:<uid|sid> ENCAP <target> <subcommand> [<args>...]
+func Encap(hook string, msg *Message, ircd *IRCd) {
+	target := msg.Args[0]
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			ircd.ToServer <- &Message{
+				Prefix:  msg.Prefix,
+				Command: CMD_ENCAP,
+				Args:    msg.Args,
+				DestIDs: []string{sid},
+			}
+		}
+	}
+
+	if match, _ := filepath.Match(ToLower(target), ToLower(Config.Name)); !match {
+		return
+	}
+	handler, ok := encapHandlers[strings.ToUpper(msg.Args[1])]
+	if !ok {
+		return
+	}
+	handler(&Message{
+		SenderID: msg.SenderID,
+		Prefix:   msg.Prefix,
+		Command:  strings.ToUpper(msg.Args[1]),
+		Args:     msg.Args[2:],
+	}, ircd)
+}
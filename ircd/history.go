@@ -0,0 +1,260 @@
+package ircd
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The number of messages kept in the history of each target unless the
+// configuration says otherwise.
+const DefaultHistoryLength = 100
+
+// A HistoryItem is a PRIVMSG or NOTICE recorded in the history of a target.
+// A private message also records who took part in the conversation (see
+// historyIdentity), so that it is not shown to whoever takes their nicks.
+type HistoryItem struct {
+	Tags         map[string]string `json:"tags"`
+	Prefix       string            `json:"prefix"`
+	Command      string            `json:"command"`
+	Target       string            `json:"target"`
+	Text         string            `json:"text"`
+	Time         time.Time         `json:"time"`
+	Participants []string          `json:"participants,omitempty"`
+}
+
+// ID returns the msgid of the message.
+func (h *HistoryItem) ID() string {
+	return h.Tags[tagMsgID]
+}
+
+// Message returns the message to replay to the user.
+func (h *HistoryItem) Message(destIDs ...string) *Message {
+	return &Message{
+		Tags:    h.Tags,
+		Prefix:  h.Prefix,
+		Command: h.Command,
+		Args: []string{
+			h.Target,
+			h.Text,
+		},
+		DestIDs: destIDs,
+	}
+}
+
+// A HistoryStore records the messages sent to each target (see
+// historyTarget), keeping only the most recent.
+type HistoryStore interface {
+	// Add records a message in the history of the target.
+	Add(target string, item *HistoryItem) error
+
+	// Get returns the history of the target, oldest first.
+	Get(target string) ([]*HistoryItem, error)
+
+	// Targets returns the targets which have a history.
+	Targets() ([]string, error)
+}
+
+// The store in which messages are recorded.  It is replaced when the server
+// starts if a history file is configured.
+var History HistoryStore = NewMemoryHistory(DefaultHistoryLength)
+
+// historyTarget returns the name under which the history of a channel, or
+// of the private conversation between two nicks, is stored.
+func historyTarget(names ...string) string {
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = ToLower(name)
+	}
+	sort.Strings(lower)
+	return strings.Join(lower, " ")
+}
+
+// A MemoryHistory is a HistoryStore which is lost when the server exits.
+type MemoryHistory struct {
+	mutex  *sync.RWMutex
+	length int
+	count  int
+	items  map[string][]*HistoryItem
+}
+
+// NewMemoryHistory returns an empty history which keeps the given number of
+// messages for each target.
+func NewMemoryHistory(length int) *MemoryHistory {
+	return &MemoryHistory{
+		mutex:  new(sync.RWMutex),
+		length: length,
+		items:  make(map[string][]*HistoryItem),
+	}
+}
+
+// Add implements HistoryStore.  Messages are kept in order of their time,
+// since those from other servers may arrive late.
+func (m *MemoryHistory) Add(target string, item *HistoryItem) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	items := append(m.items[target], item)
+	for i := len(items) - 1; i > 0 && items[i-1].Time.After(item.Time); i-- {
+		items[i], items[i-1] = items[i-1], items[i]
+	}
+	m.count++
+	if len(items) > m.length {
+		m.count -= len(items) - m.length
+		items = items[len(items)-m.length:]
+	}
+	m.items[target] = items
+	return nil
+}
+
+// Get implements HistoryStore.
+func (m *MemoryHistory) Get(target string) ([]*HistoryItem, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return append([]*HistoryItem(nil), m.items[target]...), nil
+}
+
+// Targets implements HistoryStore.
+func (m *MemoryHistory) Targets() ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	targets := make([]string, 0, len(m.items))
+	for target := range m.items {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// Len returns the number of messages in the history.
+func (m *MemoryHistory) Len() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.count
+}
+
+// A DiskHistory is a HistoryStore which is kept in a file so that it
+// survives restarts.  Messages are appended to the file as they are added,
+// and the file is rewritten once it holds twice as many as the history.
+type DiskHistory struct {
+	*MemoryHistory
+	mutex    *sync.Mutex
+	filename string
+	file     *os.File
+	lines    int
+}
+
+// A diskHistoryLine is a line in the history file.
+type diskHistoryLine struct {
+	Target string       `json:"target"`
+	Item   *HistoryItem `json:"item"`
+}
+
+// OpenDiskHistory reads the history file (if it exists) and returns the
+// history, which keeps the given number of messages for each target.
+func OpenDiskHistory(filename string, length int) (*DiskHistory, error) {
+	d := &DiskHistory{
+		MemoryHistory: NewMemoryHistory(length),
+		mutex:         new(sync.Mutex),
+		filename:      filename,
+	}
+
+	f, err := os.Open(filename)
+	switch {
+	case err == nil:
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 16*MaxLineLength)
+		for scanner.Scan() {
+			var line diskHistoryLine
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || line.Item == nil {
+				Warn.Printf("Skipping invalid line in history %s", filename)
+				continue
+			}
+			d.MemoryHistory.Add(line.Target, line.Item)
+			d.lines++
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	if err := d.compact(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// compact rewrites the history file with only the messages in the history.
+// The caller must hold the mutex (or be opening the history).
+func (d *DiskHistory) compact() error {
+	tmp := d.filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	lines := 0
+	targets, _ := d.MemoryHistory.Targets()
+	for _, target := range targets {
+		items, _ := d.MemoryHistory.Get(target)
+		for _, item := range items {
+			if err := enc.Encode(diskHistoryLine{target, item}); err != nil {
+				f.Close()
+				return err
+			}
+			lines++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, d.filename); err != nil {
+		return err
+	}
+
+	if d.file != nil {
+		d.file.Close()
+	}
+	d.file, err = os.OpenFile(d.filename, os.O_WRONLY|os.O_APPEND, 0600)
+	d.lines = lines
+	return err
+}
+
+// Add implements HistoryStore.
+func (d *DiskHistory) Add(target string, item *HistoryItem) error {
+	d.MemoryHistory.Add(target, item)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	b, err := json.Marshal(diskHistoryLine{target, item})
+	if err != nil {
+		return err
+	}
+	if _, err := d.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	d.lines++
+	if d.lines > 2*d.Len() && d.lines > d.length {
+		return d.compact()
+	}
+	return nil
+}
+
+// Close closes the history file.
+func (d *DiskHistory) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.file.Close()
+}
@@ -0,0 +1,190 @@
+package ircd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var historyEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testHistory returns messages with msgids 1 to n sent a second apart.
+func testHistory(n int) []*HistoryItem {
+	items := []*HistoryItem{}
+	for i := 1; i <= n; i++ {
+		items = append(items, &HistoryItem{
+			Tags: map[string]string{tagMsgID: strconv.Itoa(i)},
+			Time: historyEpoch.Add(time.Duration(i) * time.Second),
+		})
+	}
+	return items
+}
+
+// historyIDs returns the msgids of the items separated by commas.
+func historyIDs(items []*HistoryItem) string {
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item.ID())
+	}
+	return strings.Join(ids, ",")
+}
+
+var selectHistoryTests = []struct {
+	Sub   string
+	Refs  []string
+	Limit int
+	IDs   string
+}{
+	{"LATEST", nil, 3, "8,9,10"},
+	{"LATEST", []string{"msgid=7"}, 5, "8,9,10"},
+	{"BEFORE", []string{"msgid=4"}, 5, "1,2,3"},
+	{"BEFORE", []string{"timestamp=2020-01-01T00:00:06.000Z"}, 2, "4,5"},
+	{"AFTER", []string{"msgid=4"}, 2, "5,6"},
+	{"AFTER", []string{"timestamp=2020-01-01T00:00:08.500Z"}, 5, "9,10"},
+	{"AROUND", []string{"msgid=5"}, 4, "3,4,5,6"},
+	{"AROUND", []string{"msgid=1"}, 3, "1,2,3"},
+	{"BETWEEN", []string{"msgid=2", "msgid=8"}, 3, "3,4,5"},
+	{"BETWEEN", []string{"msgid=8", "msgid=2"}, 3, "5,6,7"},
+	{"BETWEEN", []string{"msgid=5", "msgid=5"}, 3, ""},
+	{"BEFORE", []string{"msgid=missing"}, 3, ""},
+}
+
+func TestSelectHistory(t *testing.T) {
+	items := testHistory(10)
+	for idx, test := range selectHistoryTests {
+		refs := []historyRef{}
+		for _, arg := range test.Refs {
+			ref, ok := parseHistoryRef(arg)
+			if !ok {
+				t.Fatalf("#%d: parseHistoryRef(%q) failed", idx, arg)
+			}
+			refs = append(refs, ref)
+		}
+		got := historyIDs(selectHistory(items, test.Sub, refs, test.Limit))
+		if got != test.IDs {
+			t.Errorf("#%d: %s %v %d = %q, want %q", idx, test.Sub, test.Refs, test.Limit, got, test.IDs)
+		}
+	}
+
+	for _, arg := range []string{"*", "msgid=", "timestamp=yesterday", "id=1"} {
+		if _, ok := parseHistoryRef(arg); ok {
+			t.Errorf("parseHistoryRef(%q) succeeded", arg)
+		}
+	}
+}
+
+func TestMemoryHistory(t *testing.T) {
+	h := NewMemoryHistory(3)
+	items := testHistory(5)
+	// Messages may arrive out of order
+	for _, i := range []int{0, 2, 1, 4, 3} {
+		h.Add("#chan", items[i])
+	}
+	got, _ := h.Get("#chan")
+	if got, want := historyIDs(got), "3,4,5"; got != want {
+		t.Errorf("Get = %q, want %q", got, want)
+	}
+	if got, want := h.Len(), 3; got != want {
+		t.Errorf("Len = %d, want %d", got, want)
+	}
+}
+
+func TestDiskHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("TempDir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "history")
+
+	h, err := OpenDiskHistory(filename, 2)
+	if err != nil {
+		t.Fatalf("OpenDiskHistory: %s", err)
+	}
+	for _, item := range testHistory(10) {
+		if err := h.Add(historyTarget("Alice", "bob"), item); err != nil {
+			t.Fatalf("Add: %s", err)
+		}
+	}
+	h.Add("#chan", testHistory(1)[0])
+	h.Close()
+
+	h, err = OpenDiskHistory(filename, 2)
+	if err != nil {
+		t.Fatalf("OpenDiskHistory: %s", err)
+	}
+	defer h.Close()
+	targets, _ := h.Targets()
+	if got, want := strings.Join(targets, ","), "#chan,alice bob"; got != want {
+		t.Errorf("Targets = %q, want %q", got, want)
+	}
+	items, _ := h.Get("alice bob")
+	if got, want := historyIDs(items), "9,10"; got != want {
+		t.Errorf("Get = %q, want %q", got, want)
+	}
+	if !items[1].Time.Equal(historyEpoch.Add(10 * time.Second)) {
+		t.Errorf("Time = %s", items[1].Time)
+	}
+}
+
+func TestPrivateHistory(t *testing.T) {
+	testConfig(t)
+	defer func(history HistoryStore) { History = history }(History)
+	History = NewMemoryHistory(10)
+
+	testUser(t, Config.SID, "alice")
+	bob := testUser(t, Config.SID, "bob")
+	carol := testUser(t, Config.SID, "carol")
+	GetUser(carol).SetAccount("Carol")
+
+	for _, sender := range []string{"bob", "carol"} {
+		handlerTest{
+			Desc:   "message from " + sender,
+			Hook:   CMD_PRIVMSG,
+			Func:   Privmsg,
+			Sender: sender,
+			Args:   []string{"alice", "secret from " + sender},
+			ToClient: []string{
+				"alice @msgid=*;time=* :" + sender + " PRIVMSG * :secret from " + sender,
+			},
+		}.run(t)
+	}
+
+	latest := func(desc, sender, target string, lines ...string) {
+		t.Helper()
+		handlerTest{
+			Desc:     desc,
+			Hook:     CMD_CHATHISTORY,
+			Func:     Chathistory,
+			Sender:   sender,
+			Args:     []string{"LATEST", target, "*", "10"},
+			ToClient: lines,
+		}.run(t)
+	}
+	latest("bob's history", "bob", "alice",
+		"bob @msgid=*;time=* :bob!bob@bob.example PRIVMSG alice :secret from bob")
+
+	// Whoever takes a nick does not see the conversations of its last user
+	Delete(bob)
+	Delete(carol)
+	testUser(t, Config.SID, "bob")
+	latest("new bob's history", "bob", "alice")
+	handlerTest{
+		Desc:   "new bob's targets",
+		Hook:   CMD_CHATHISTORY,
+		Func:   Chathistory,
+		Sender: "bob",
+		Args:   []string{"TARGETS", "timestamp=2000-01-01T00:00:00.000Z", "timestamp=2100-01-01T00:00:00.000Z", "10"},
+	}.run(t)
+
+	// Unless they are logged in to the same account
+	GetUser(testUser(t, Config.SID, "carol")).SetAccount("carol")
+	latest("carol's history", "carol", "alice",
+		"carol @account=Carol;msgid=*;time=* :carol!carol@carol.example PRIVMSG alice :secret from carol")
+	latest("alice's history", "alice", "bob",
+		"alice @msgid=*;time=* :bob!bob@bob.example PRIVMSG alice :secret from bob")
+}
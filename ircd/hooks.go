@@ -50,6 +50,10 @@ type Hook struct {
 	Constraints CallConstraints
 	Calls       int
 	Func        func(hook string, message *Message, ircd *IRCd)
+
+	// Ordered hooks are called for a client's messages one at a time, in
+	// the order in which the messages were received.
+	Ordered bool
 }
 
 var (
@@ -70,6 +74,12 @@ func Register(hook string, when ExecutionMask, args CallConstraints,
 	return h
 }
 
+// InOrder marks the hook as ordered and returns it.
+func (h *Hook) InOrder() *Hook {
+	h.Ordered = true
+	return h
+}
+
 // TODO(kevlar): Add channel to send messages back on
 func DispatchClient(message *Message, ircd *IRCd) {
 	hookName := message.Command
@@ -84,15 +94,35 @@ func DispatchClient(message *Message, ircd *IRCd) {
 	case RegisteredAsUser:
 		mask |= EMASK_USER
 	}
+	call := func(fn func(string, *Message, *IRCd)) {
+		go fn(hookName, message, ircd)
+	}
+	if r := labelReplies(hookName, message, ircd); r != nil {
+		call = r.call
+		defer r.finish()
+	}
 	for _, hook := range registeredHooks[hookName] {
 		if hook.When&mask == mask {
-			// TODO(kevlar): Check callconstraints
-			go hook.Func(hookName, message, ircd)
+			if len(message.Args) < hook.Constraints.MinArgs {
+				call(needMoreParams)
+				continue
+			}
+			fn := hook.Func
+			if hook.Ordered {
+				fn = GetUser(message.SenderID).inOrder(fn)
+			}
+			call(fn)
 			hook.Calls++
 		}
 	}
 }
 
+// needMoreParams informs the sender that the hook was not called because
+// too few arguments were given.
+func needMoreParams(hook string, msg *Message, ircd *IRCd) {
+	ircd.ToClient <- NewNumeric(ERR_NEEDMOREPARAMS, hook).Message(msg.SenderID)
+}
+
 func DispatchServer(message *Message, ircd *IRCd) {
 	hookName := message.Command
 	_, _, _, reg, ok := GetServerInfo(message.SenderID)
@@ -109,7 +139,11 @@ func DispatchServer(message *Message, ircd *IRCd) {
 	}
 	for _, hook := range registeredHooks[hookName] {
 		if hook.When&mask == mask {
-			// TODO(kevlar): Check callconstraints
+			if len(message.Args) < hook.Constraints.MinArgs {
+				Warn.Printf("Dropping %s from %s: %d args, want at least %d",
+					hookName, message.SenderID, len(message.Args), hook.Constraints.MinArgs)
+				continue
+			}
 			go hook.Func(hookName, message, ircd)
 			hook.Calls++
 		}
@@ -0,0 +1,97 @@
+package ircd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The port on which identd listens (RFC 1413).
+const IdentPort = 113
+
+var (
+	// The dialer used to connect to the identd of connecting clients.  Tests
+	// may replace it with one which connects to a fake.
+	IdentDialer Dialer = new(net.Dialer)
+
+	// How long to wait for an ident response before giving up.
+	IdentTimeout = 5 * time.Second
+)
+
+// A Dialer connects to a remote address.
+type Dialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// Ident queries the identd on the remote host of a connection with the given
+// local and remote addresses and returns the user ID it reports.  If there is
+// no identd, it reports an error, or it does not respond within the timeout,
+// ok is false.  The remote host is not queried if the remote port is unknown
+// (0).
+func Ident(d Dialer, local, remote net.Addr, timeout time.Duration) (user string, ok bool) {
+	_, lport, err := net.SplitHostPort(local.String())
+	if err != nil {
+		return "", false
+	}
+	rhost, rport, err := net.SplitHostPort(remote.String())
+	if err != nil || rport == "0" {
+		return "", false
+	}
+
+	found := make(chan string, 1)
+	go func() {
+		defer close(found)
+		conn, err := d.Dial("tcp", net.JoinHostPort(rhost, strconv.Itoa(IdentPort)))
+		if err != nil {
+			Debug.Printf("Ident for %s: %s", rhost, err)
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(timeout))
+
+		fmt.Fprintf(conn, "%s , %s\r\n", rport, lport)
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			Debug.Printf("Ident for %s: %s", rhost, err)
+			return
+		}
+		if user, ok := parseIdent(line, rport, lport); ok {
+			found <- user
+		}
+	}()
+
+	select {
+	case user, ok = <-found:
+	case <-time.After(timeout):
+	}
+	return
+}
+
+// parseIdent parses an ident response of the form
+//
+//	<port-on-server> , <port-on-client> : USERID : <opsys> : <user-id>
+//
+// for the given ports and returns the user ID if it can be used as a
+// username.
+func parseIdent(line, rport, lport string) (user string, ok bool) {
+	fields := strings.SplitN(strings.TrimRight(line, "\r\n"), ":", 4)
+	if len(fields) != 4 || strings.TrimSpace(fields[1]) != "USERID" {
+		return "", false
+	}
+
+	ports := strings.Split(fields[0], ",")
+	if len(ports) != 2 ||
+		strings.TrimSpace(ports[0]) != rport ||
+		strings.TrimSpace(ports[1]) != lport {
+		return "", false
+	}
+
+	user = strings.TrimSpace(fields[3])
+	if !ValidUser(user) {
+		return "", false
+	}
+	return user, true
+}
@@ -0,0 +1,105 @@
+package ircd
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDialer connects to addr instead of the requested address.
+type fakeDialer struct {
+	addr  string
+	dials int
+}
+
+func (d *fakeDialer) Dial(network, address string) (net.Conn, error) {
+	d.dials++
+	if len(d.addr) == 0 {
+		return nil, errors.New("connection refused")
+	}
+	return net.Dial(network, d.addr)
+}
+
+// fakeIdentd answers each query it receives with the response, in which
+// %q is replaced by the query.  An empty response is never sent.
+func fakeIdentd(t *testing.T, response string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				query, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil || len(response) == 0 {
+					time.Sleep(time.Second)
+					return
+				}
+				query = strings.TrimSpace(query)
+				conn.Write([]byte(strings.Replace(response, "%q", query, -1) + "\r\n"))
+			}(conn)
+		}
+	}()
+	return l
+}
+
+func TestIdent(t *testing.T) {
+	local := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 6667}
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 40000}
+
+	tests := []struct {
+		Response string
+		Refused  bool
+		User     string
+		OK       bool
+	}{
+		{Response: "%q : USERID : UNIX : kevlar", User: "kevlar", OK: true},
+		{Response: "40000,6667:USERID:UNIX:kevlar", User: "kevlar", OK: true},
+		{Response: "%q : ERROR : NO-USER"},
+		{Response: "40001 , 6667 : USERID : UNIX : kevlar"},
+		{Response: "%q : USERID : UNIX : bad user"},
+		{Response: ""},
+		{Refused: true},
+	}
+
+	for idx, test := range tests {
+		d := &fakeDialer{}
+		if !test.Refused {
+			l := fakeIdentd(t, test.Response)
+			defer l.Close()
+			d.addr = l.Addr().String()
+		}
+
+		user, ok := Ident(d, local, remote, 50*time.Millisecond)
+		if got, want := user, test.User; got != want {
+			t.Errorf("#%d: Ident() user = %q, want %q", idx, got, want)
+		}
+		if got, want := ok, test.OK; got != want {
+			t.Errorf("#%d: Ident() ok = %v, want %v", idx, got, want)
+		}
+	}
+}
+
+func TestIdentNoPort(t *testing.T) {
+	l := fakeIdentd(t, "%q : USERID : UNIX : kevlar")
+	defer l.Close()
+	d := &fakeDialer{addr: l.Addr().String()}
+
+	// The address of a client behind a proxy has no port
+	local := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 6667}
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2)}
+	if user, ok := Ident(d, local, remote, 50*time.Millisecond); ok {
+		t.Errorf("Ident() = %q, true; want false", user)
+	}
+	if d.dials != 0 {
+		t.Errorf("Ident() dialed %d times, want 0", d.dials)
+	}
+}
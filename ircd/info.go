@@ -0,0 +1,197 @@
+package ircd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	infohooks = []*Hook{
+		Register(CMD_LUSERS, EMASK_USER, AnyArgs, Lusers),
+		Register(CMD_MOTD, EMASK_USER, AnyArgs, Motd),
+	}
+)
+
+var (
+	// The time at which the server was started.
+	Created = time.Now()
+
+	// The maximum number of RPL_ISUPPORT tokens sent in one message.
+	ISupportPerLine = 13
+)
+
+// The highest user counts seen since the server was started.
+var (
+	maxMutex  = new(sync.Mutex)
+	maxLocal  int
+	maxGlobal int
+)
+
+// modeChars returns the sorted characters of the modes of the given types.
+func modeChars(modes ModeMap, types ...modeType) string {
+	chars := []string{}
+	for ch, spec := range modes {
+		for _, typ := range types {
+			if spec.Type() == typ {
+				chars = append(chars, string(ch))
+				break
+			}
+		}
+	}
+	sort.Strings(chars)
+	s := ""
+	for _, ch := range chars {
+		s += ch
+	}
+	return s
+}
+
+// ISupport returns the RPL_ISUPPORT tokens which describe this server.
+func ISupport() []string {
+	tokens := []string{
+		"CHANTYPES=#",
+		"CHANMODES=" + modeChars(ChannelModes, ListMode) +
+			"," + modeChars(ChannelModes, KeyMode) +
+			"," + modeChars(ChannelModes, LimitMode) +
+			"," + modeChars(ChannelModes, FlagMode),
+		"PREFIX=(" + statusMode + ")" + statusPrefix,
+	}
+	if _, ok := ChannelModes['e']; ok {
+		tokens = append(tokens, "EXCEPTS=e")
+	}
+	if _, ok := ChannelModes['I']; ok {
+		tokens = append(tokens, "INVEX=I")
+	}
+	tokens = append(tokens,
+		"SAFELIST",
+		"ELIST=CMNTU",
+		"CASEMAPPING=rfc1459",
+		"NICKLEN="+strconv.Itoa(MaxNickLength),
+		"CHANNELLEN="+strconv.Itoa(MaxChannelLength),
+		"TOPICLEN="+strconv.Itoa(MaxTopicLength),
+		"AWAYLEN="+strconv.Itoa(MaxAwayLength),
+		"MONITOR="+strconv.Itoa(MaxMonitor),
+		"CHATHISTORY="+strconv.Itoa(MaxHistoryRequest),
+		"MSGREFTYPES=msgid,timestamp",
+		"NETWORK="+Config.Network.Name,
+	)
+	return tokens
+}
+
+// sendWelcome sends RPL_CREATED, RPL_MYINFO, and RPL_ISUPPORT to the user.
+func sendWelcome(uid string, ircd *IRCd) {
+	msg := NewNumeric(RPL_CREATED).Message(uid)
+	msg.Args[1] = "This server was created " + Created.Format(time.RFC1123)
+	ircd.ToClient <- msg
+
+	ircd.ToClient <- NewNumeric(RPL_MYINFO,
+		Config.Name,
+		REPO_VERSION,
+		modeChars(UserModes, UserMode),
+		modeChars(ChannelModes, StatusMode, ListMode, KeyMode, LimitMode, FlagMode),
+		modeChars(ChannelModes, StatusMode, ListMode, KeyMode, LimitMode),
+	).Message(uid)
+
+	tokens := ISupport()
+	for len(tokens) > 0 {
+		n := len(tokens)
+		if n > ISupportPerLine {
+			n = ISupportPerLine
+		}
+		args := append([]string{"*"}, tokens[:n]...)
+		ircd.ToClient <- &Message{
+			Command: RPL_ISUPPORT,
+			Args:    append(args, "are supported by this server"),
+			DestIDs: []string{uid},
+		}
+		tokens = tokens[n:]
+	}
+}
+
+// Handle a LUSERS from a local client.
+//
+//	LUSERS
+func Lusers(hook string, msg *Message, ircd *IRCd) {
+	sendLusers(msg.SenderID, ircd)
+}
+
+// sendLusers sends the user, server, and channel counts to the user.
+func sendLusers(uid string, ircd *IRCd) {
+	local, global, invisible, opers, unknown := UserCount()
+	localServers, globalServers := ServerCount()
+	channels := 0
+	for _ = range ChannelIter() {
+		channels++
+	}
+
+	maxMutex.Lock()
+	if local > maxLocal {
+		maxLocal = local
+	}
+	if global > maxGlobal {
+		maxGlobal = global
+	}
+	maxL, maxG := maxLocal, maxGlobal
+	maxMutex.Unlock()
+
+	msg := NewNumeric(RPL_LUSERCLIENT).Message(uid)
+	msg.Args[1] = fmt.Sprintf("There are %d users and %d invisible on %d servers",
+		global-invisible, invisible, globalServers+1)
+	ircd.ToClient <- msg
+
+	if opers > 0 {
+		ircd.ToClient <- NewNumeric(RPL_LUSEROP, strconv.Itoa(opers)).Message(uid)
+	}
+	if unknown > 0 {
+		ircd.ToClient <- NewNumeric(RPL_LUSERUNKNOWN, strconv.Itoa(unknown)).Message(uid)
+	}
+	if channels > 0 {
+		ircd.ToClient <- NewNumeric(RPL_LUSERCHANNELS, strconv.Itoa(channels)).Message(uid)
+	}
+
+	msg = NewNumeric(RPL_LUSERME).Message(uid)
+	msg.Args[1] = fmt.Sprintf("I have %d clients and %d servers", local, localServers)
+	ircd.ToClient <- msg
+
+	msg = NewNumeric(RPL_LOCALUSERS, strconv.Itoa(local), strconv.Itoa(maxL)).Message(uid)
+	msg.Args[3] = fmt.Sprintf("Current local users %d, max %d", local, maxL)
+	ircd.ToClient <- msg
+
+	msg = NewNumeric(RPL_GLOBALUSERS, strconv.Itoa(global), strconv.Itoa(maxG)).Message(uid)
+	msg.Args[3] = fmt.Sprintf("Current global users %d, max %d", global, maxG)
+	ircd.ToClient <- msg
+}
+
+// Handle a MOTD from a local client.
+//
+//	MOTD
+func Motd(hook string, msg *Message, ircd *IRCd) {
+	sendMotd(msg.SenderID, ircd)
+}
+
+// sendMotd sends the message of the day to the user.
+func sendMotd(uid string, ircd *IRCd) {
+	if len(Config.MOTD) == 0 {
+		ircd.ToClient <- NewNumeric(ERR_NOMOTD).Message(uid)
+		return
+	}
+	lines, err := ReadMOTD(Config.MOTD)
+	if err != nil {
+		Debug.Printf("Reading MOTD: %s", err)
+		ircd.ToClient <- NewNumeric(ERR_NOMOTD).Message(uid)
+		return
+	}
+
+	msg := NewNumeric(RPL_MOTDSTART).Message(uid)
+	msg.Args[1] = "- " + Config.Name + " Message of the day - "
+	ircd.ToClient <- msg
+	for _, line := range lines {
+		msg = NewNumeric(RPL_MOTD).Message(uid)
+		msg.Args[1] = "- " + line
+		ircd.ToClient <- msg
+	}
+	ircd.ToClient <- NewNumeric(RPL_ENDOFMOTD).Message(uid)
+}
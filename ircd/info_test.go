@@ -0,0 +1,26 @@
+package ircd
+
+import (
+	"testing"
+)
+
+func TestModeChars(t *testing.T) {
+	tests := []struct {
+		Modes ModeMap
+		Types []modeType
+		Chars string
+	}{
+		{UserModes, []modeType{UserMode}, "DSZaiorw"},
+		{ChannelModes, []modeType{StatusMode}, "hov"},
+		{ChannelModes, []modeType{ListMode}, "Ibe"},
+		{ChannelModes, []modeType{KeyMode, LimitMode}, "kl"},
+		{ChannelModes, []modeType{FlagMode}, "imnprst"},
+		{ChannelModes, nil, ""},
+	}
+
+	for idx, test := range tests {
+		if got, want := modeChars(test.Modes, test.Types...), test.Chars; got != want {
+			t.Errorf("#%d: modeChars(%v) = %q, want %q", idx, test.Types, got, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package ircd
 
 import (
+	"crypto/tls"
 	"sync"
 )
 
@@ -70,7 +71,13 @@ func (s *IRCd) manageServers() {
 		case msg, open = <-s.ToServer:
 			// Count the number of messages sent
 			sentcount := 0
+			tags := msg.Tags
 			for _, dest := range msg.DestIDs {
+				// Only send tags to the links which support them
+				msg.Tags = serverTags(dest, tags)
+				if msg.Command == CMD_TAGMSG && len(msg.Tags) == 0 {
+					continue
+				}
 				Debug.Printf("{%v} << %s\n", dest, msg)
 
 				if conn, ok := sid2conn[dest]; ok {
@@ -207,12 +214,13 @@ func (s *IRCd) manageClients() {
 			}
 
 			// Examine all arguments for UIDs and replace them
+			tags := relayTags(msg)
 			if isuid(msg.Prefix) {
-				nick, user, _, _, ok := GetUserInfo(msg.Prefix)
+				_, _, _, _, ok := GetUserInfo(msg.Prefix)
 				if !ok {
 					Warn.Printf("Nonexistent ID %s as prefix", msg.Prefix)
 				} else {
-					msg.Prefix = nick + "!" + user + "@host" // TODO(kevlar): hostname
+					msg.Prefix = GetUser(msg.Prefix).Hostmask()
 				}
 			}
 			for i := range msg.Args {
@@ -241,6 +249,8 @@ func (s *IRCd) manageClients() {
 						msg.Prefix = nick
 					}
 				}
+				// Only send the tags the client has asked for
+				msg.Tags = userTags(id, tags)
 				conn.WriteMessage(msg)
 				Debug.Printf("[%s] << %s\n", id, msg)
 				sentcount++
@@ -286,10 +296,15 @@ func (s *IRCd) manageIncoming() {
 		conn.Subscribe(inc)
 		conn.SubscribeClose(stop)
 
-		user, nick := false, false
+		user, nick, capneg := false, false, false
 		pass, server, capab := false, false, false
 		sid := ""
 
+		// The hostname and ident are looked up while the client registers,
+		// once the connection is known not to be a server
+		var lookup *clientLookup
+		var lookups chan *clientLookup
+
 		queued := make([]*Message, 0, 3)
 
 		for !quit {
@@ -307,16 +322,35 @@ func (s *IRCd) manageIncoming() {
 					user = true
 				case CMD_NICK:
 					nick = true
+				case CMD_CAP:
+					capneg = true
 				case CMD_CAPAB:
 					capab = true
 				case CMD_SERVER:
 					server = true
 				}
+				if lookups == nil && (user || nick || capneg) {
+					lookups = make(chan *clientLookup, 1)
+					go func(lookups chan<- *clientLookup) {
+						lookups <- lookupClient(conn)
+					}(lookups)
+				}
+			case lookup = <-lookups:
 			case <-stop:
 				return
 			}
 
-			if !quit && nick && user {
+			// Clients negotiating capabilities need replies before they
+			// can finish registering
+			if !quit && (nick && user || capneg) && lookup != nil {
+				u := GetUser(conn.ID())
+				u.SetHost(lookup.host, conn.IP())
+				if lookup.identd {
+					u.SetIdent(lookup.ident)
+				}
+				if conn.TLS() {
+					u.SetSecure(conn.Fingerprint())
+				}
 				conn.Unsubscribe(inc)
 				conn.UnsubscribeClose(stop)
 				s.newClient <- conn
@@ -348,6 +382,67 @@ func (s *IRCd) manageIncoming() {
 	}
 }
 
+// The results of the hostname and ident lookups for a connecting client.
+type clientLookup struct {
+	host   string
+	ident  string
+	identd bool // whether the ident lookup was performed
+}
+
+// lookupClient looks up the hostname of the connection and then, unless the
+// client's class has the "noident" flag, queries its identd.  The client is
+// notified of the progress.
+func lookupClient(conn *Conn) *clientLookup {
+	notice := func(text string) {
+		if conn.Active() {
+			conn.WriteMessage(authNotice(text))
+		}
+	}
+
+	lookup := &clientLookup{
+		host: conn.IP(),
+	}
+
+	notice("*** Looking up your hostname...")
+	if host, ok := ReverseLookup(DNS, conn.IP(), DNSTimeout); ok {
+		notice("*** Found your hostname")
+		lookup.host = host
+	} else {
+		notice("*** Couldn't look up your hostname")
+	}
+
+	if class := Config.FindClass(lookup.host, conn.IP()); class != nil && class.HasFlag("noident") {
+		return lookup
+	}
+	// The address of a WebSocket client may have come from a proxy, so
+	// there is no connection of theirs to ask about
+	if conn.WebSocket() {
+		return lookup
+	}
+
+	notice("*** Checking Ident")
+	lookup.identd = true
+	if ident, ok := Ident(IdentDialer, conn.LocalAddr(), conn.RemoteAddr(), IdentTimeout); ok {
+		notice("*** Got Ident response")
+		lookup.ident = ident
+	} else {
+		notice("*** No Ident response")
+	}
+	return lookup
+}
+
+// authNotice returns a NOTICE AUTH message for an unregistered connection.
+func authNotice(text string) *Message {
+	return &Message{
+		Prefix:  Config.Name,
+		Command: CMD_NOTICE,
+		Args: []string{
+			"AUTH",
+			text,
+		},
+	}
+}
+
 var (
 	// TODO(kevlar): Configurable?
 	SendQ = 100
@@ -367,6 +462,28 @@ func Start() {
 		Error.Fatalf("Could not start: invalid configuration")
 	}
 
+	if len(Config.Accounts) > 0 {
+		accounts, err := LoadAccounts(Config.Accounts)
+		if err != nil {
+			Error.Fatalf("Could not load accounts: %s", err)
+		}
+		Accounts = accounts
+	}
+
+	length := Config.HistLen
+	if length <= 0 {
+		length = DefaultHistoryLength
+	}
+	History = NewMemoryHistory(length)
+	if len(Config.History) > 0 {
+		history, err := OpenDiskHistory(Config.History, length)
+		if err != nil {
+			Error.Fatalf("Could not open history: %s", err)
+		}
+		defer history.Close()
+		History = history
+	}
+
 	listener := NewListener()
 	defer listener.Close()
 	for _, ports := range Config.Ports {
@@ -374,8 +491,23 @@ func Start() {
 		if err != nil {
 			Warn.Print(err)
 		}
+		var tlsConfig *tls.Config
+		if ports.SSL {
+			tlsConfig, err = Config.TLSConfig()
+			if err != nil {
+				Warn.Printf("Skipping SSL ports %s: %s", ports.PortString, err)
+				continue
+			}
+		}
 		for _, port := range portlist {
-			listener.AddPort(port)
+			switch {
+			case ports.WebSocket:
+				listener.AddWebSocketPort(port, tlsConfig, ports.Origins, ports.Proxies)
+			case ports.SSL:
+				listener.AddSSLPort(port, tlsConfig)
+			default:
+				listener.AddPort(port)
+			}
 		}
 	}
 
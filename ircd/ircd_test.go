@@ -0,0 +1,128 @@
+package ircd
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testConfig sets up the configuration for a handler test, under which the
+// users made by NextUserID are local, until the test ends.
+func testConfig(t *testing.T) {
+	old := Config
+	conf := DefaultConfiguration
+	conf.SID = UserIDPrefix
+	Config = &conf
+	t.Cleanup(func() {
+		Config = old
+	})
+}
+
+// testLink links a server directly to this one until the test ends.
+func testLink(t *testing.T, sid string) {
+	s := GetServer(sid, true)
+	s.SetServer(strings.ToLower(sid)+".test", "1")
+	s.SetType(RegisteredAsServer)
+	t.Cleanup(func() {
+		Unlink(sid)
+	})
+}
+
+// testUser registers a user with the nick on the server (which is this one
+// if sid is Config.SID) and returns their UID.  The user leaves every
+// channel and is deleted when the test ends.
+func testUser(t *testing.T, sid, nick string) string {
+	uid := sid + NextUserID()[3:]
+	u := GetUser(uid)
+	if err := u.SetNick(nick); err != nil {
+		t.Fatalf("SetNick(%q): %s", nick, err)
+	}
+	u.SetUser(strings.ToLower(nick), nick+" User")
+	u.SetHost(strings.ToLower(nick)+".example", "127.0.0.1")
+	u.SetType(RegisteredAsUser)
+	t.Cleanup(func() {
+		PartAll(uid)
+		Delete(uid)
+	})
+	return uid
+}
+
+// testIRCd returns an IRCd which keeps the messages sent to it for the test
+// to read with sentLines.
+func testIRCd() *IRCd {
+	return &IRCd{
+		ToClient: make(chan *Message, 100),
+		ToServer: make(chan *Message, 100),
+	}
+}
+
+// sentLines returns the messages sent on the channel since it was last read,
+// each as "<dest>{,<dest>} <line>", with the UIDs replaced by nicks, the
+// destinations sorted, and the values of the msgid and time tags (which
+// change every time) replaced by "*".
+func sentLines(ch chan *Message) []string {
+	lines := []string{}
+	for {
+		select {
+		case msg := <-ch:
+			msg = msg.Dup()
+			for _, name := range []string{tagMsgID, tagTime} {
+				if _, ok := msg.Tags[name]; ok {
+					msg.Tags[name] = "*"
+				}
+			}
+			dests := make([]string, len(msg.DestIDs))
+			for i, id := range msg.DestIDs {
+				dests[i] = testNick(id)
+			}
+			sort.Strings(dests)
+			line := msg.String()
+			for _, word := range strings.Fields(line) {
+				word = strings.TrimLeft(word, ":@+")
+				if isuid(word) {
+					line = strings.Replace(line, word, testNick(word), -1)
+				}
+			}
+			lines = append(lines, strings.Join(dests, ",")+" "+line)
+		default:
+			return lines
+		}
+	}
+}
+
+// testUIDs replaces the nicks of users (which may have status prefixes) among
+// the space-separated words of the argument with their UIDs.
+func testUIDs(arg string) string {
+	words := strings.Split(arg, " ")
+	for i, word := range words {
+		nick := strings.TrimLeft(word, statusPrefix)
+		if id, err := GetID(nick); err == nil && len(nick) > 0 {
+			words[i] = word[:len(word)-len(nick)] + id
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// testNick returns the nick of the user, or the ID if it is not a user.
+func testNick(id string) string {
+	if nick, _, _, _, ok := GetUserInfo(id); ok {
+		return nick
+	}
+	return id
+}
+
+// checkLines reports the differences between the lines which were sent
+// and those which were expected.
+func checkLines(t *testing.T, desc string, got, want []string) {
+	t.Helper()
+	for i := 0; i < len(got) || i < len(want); i++ {
+		switch {
+		case i >= len(want):
+			t.Errorf("%s: extra line %q", desc, got[i])
+		case i >= len(got):
+			t.Errorf("%s: missing line %q", desc, want[i])
+		case got[i] != want[i]:
+			t.Errorf("%s: line %d = %q, want %q", desc, i, got[i], want[i])
+		}
+	}
+}
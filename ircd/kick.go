@@ -0,0 +1,249 @@
+package ircd
+
+import (
+	"strings"
+)
+
+var (
+	kickhooks = []*Hook{
+		Register(CMD_KICK, EMASK_USER, OptArgs(2, 1), Kick),
+		Register(CMD_KICK, EMASK_SERVER, OptArgs(2, 1), SKick),
+		Register(CMD_INVITE, EMASK_USER, NArgs(2), Invite),
+		Register(CMD_INVITE, EMASK_SERVER, OptArgs(2, 1), SInvite),
+	}
+)
+
+// kickUser removes the target from the channel and notifies the local
+// members.  The source is the UID or SID of the kicker.
+func kickUser(source, target string, channel *Channel, reason string, ircd *IRCd) error {
+	notify, err := channel.Part(target)
+	if err != nil {
+		return err
+	}
+	if local := localIDs(notify); len(local) > 0 {
+		prefix := source
+		if len(source) == 3 {
+			prefix = sourceName(source)
+		}
+		ircd.ToClient <- &Message{
+			Prefix:  prefix,
+			Command: CMD_KICK,
+			Args: []string{
+				channel.Name(),
+				target,
+				reason,
+			},
+			DestIDs: local,
+		}
+	}
+	return nil
+}
+
+// Handle a KICK from a local client.  Either one channel and any number of
+// users or the same number of channels and users may be given.
+//
+//	KICK <channel>{,<channel>} <user>{,<user>} [:<reason>]
+func Kick(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	channels := strings.Split(msg.Args[0], ",")
+	nicks := strings.Split(msg.Args[1], ",")
+	reason, _, _, _, _ := GetUserInfo(uid)
+	if len(msg.Args) > 2 && len(msg.Args[2]) > 0 {
+		reason = msg.Args[2]
+	}
+
+	if len(channels) != 1 && len(channels) != len(nicks) {
+		ircd.ToClient <- NewNumeric(ERR_NEEDMOREPARAMS, hook).Message(uid)
+		return
+	}
+
+	for i, nick := range nicks {
+		name := channels[0]
+		if len(channels) > 1 {
+			name = channels[i]
+		}
+
+		channel, err := GetChannel(name, false)
+		if num, ok := err.(*Numeric); ok {
+			ircd.ToClient <- num.Message(uid)
+			continue
+		}
+		if !channel.OnChan(uid) {
+			ircd.ToClient <- NewNumeric(ERR_NOTONCHANNEL, channel.Name()).Message(uid)
+			continue
+		}
+
+		target, err := GetID(nick)
+		if num, ok := err.(*Numeric); ok {
+			ircd.ToClient <- num.Message(uid)
+			continue
+		}
+		if !channel.OnChan(target) {
+			ircd.ToClient <- NewNumeric(ERR_USERNOTINCHANNEL, nick, channel.Name()).Message(uid)
+			continue
+		}
+
+		// Halfops may only kick users who are not operators
+		status := channel.Status(uid)
+		switch {
+		case strings.Contains(status, "@"):
+		case strings.Contains(status, "%") && !strings.Contains(channel.Status(target), "@"):
+		default:
+			ircd.ToClient <- NewNumeric(ERR_CHANOPRIVSNEEDED, channel.Name()).Message(uid)
+			continue
+		}
+
+		if err := kickUser(uid, target, channel, reason, ircd); err != nil {
+			Warn.Printf("KICK %s from %s: %s", nick, channel.Name(), err)
+			continue
+		}
+
+		for sid := range ServerIter() {
+			ircd.ToServer <- &Message{
+				Prefix:  uid,
+				Command: CMD_KICK,
+				Args: []string{
+					channel.Name(),
+					target,
+					reason,
+				},
+				DestIDs: []string{sid},
+			}
+		}
+	}
+}
+
+// Handle a KICK from a linked server.
+//
+//	:<uid|sid> KICK <channel> <uid> [:<reason>]
+func SKick(hook string, msg *Message, ircd *IRCd) {
+	name, target := msg.Args[0], msg.Args[1]
+	reason := sourceName(msg.Prefix)
+	if len(msg.Prefix) != 3 {
+		reason, _, _, _, _ = GetUserInfo(msg.Prefix)
+	}
+	if len(msg.Args) > 2 {
+		reason = msg.Args[2]
+	}
+
+	channel, err := GetChannel(name, false)
+	if err != nil {
+		Warn.Printf("KICK for unknown channel %s from %s", name, msg.SenderID)
+		return
+	}
+	if err := kickUser(msg.Prefix, target, channel, reason, ircd); err != nil {
+		Warn.Printf("KICK %s from %s: %s", target, name, err)
+		return
+	}
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			fmsg := msg.Dup()
+			fmsg.DestIDs = []string{sid}
+			ircd.ToServer <- fmsg
+		}
+	}
+}
+
+// Handle an INVITE from a local client.
+//
+//	INVITE <nick> <channel>
+func Invite(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+
+	target, err := GetID(msg.Args[0])
+	if num, ok := err.(*Numeric); ok {
+		ircd.ToClient <- num.Message(uid)
+		return
+	}
+	channel, err := GetChannel(msg.Args[1], false)
+	if num, ok := err.(*Numeric); ok {
+		ircd.ToClient <- num.Message(uid)
+		return
+	}
+	if !channel.OnChan(uid) {
+		ircd.ToClient <- NewNumeric(ERR_NOTONCHANNEL, channel.Name()).Message(uid)
+		return
+	}
+	if channel.HasMode('i') && !strings.ContainsAny(channel.Status(uid), "@%") {
+		ircd.ToClient <- NewNumeric(ERR_CHANOPRIVSNEEDED, channel.Name()).Message(uid)
+		return
+	}
+	if channel.OnChan(target) {
+		ircd.ToClient <- NewNumeric(ERR_USERONCHANNEL, target, channel.Name()).Message(uid)
+		return
+	}
+
+	channel.Invite(target)
+	ircd.ToClient <- NewNumeric(RPL_INVITING, channel.Name(), target).Message(uid)
+	sendAway(uid, target, ircd)
+
+	if target[:3] == Config.SID {
+		ircd.ToClient <- &Message{
+			Prefix:  uid,
+			Command: CMD_INVITE,
+			Args: []string{
+				target,
+				channel.Name(),
+			},
+			DestIDs: []string{target},
+		}
+		return
+	}
+	for sid := range IterFor([]string{target}, "") {
+		ircd.ToServer <- &Message{
+			Prefix:  uid,
+			Command: CMD_INVITE,
+			Args: []string{
+				target,
+				channel.Name(),
+				channel.TS(),
+			},
+			DestIDs: []string{sid},
+		}
+	}
+}
+
+// Handle an INVITE from a linked server.  The invitation is recorded by the
+// invitee's server, which is where they will join from.  If the channel TS is
+// given and differs from ours, the invitation is for a different incarnation
+// of the channel and is dropped.
+//
+//	:<uid> INVITE <uid> <channel> [<channel ts>]
+func SInvite(hook string, msg *Message, ircd *IRCd) {
+	target, name := msg.Args[0], msg.Args[1]
+	if _, _, _, _, ok := GetUserInfo(target); !ok {
+		Warn.Printf("INVITE for unknown user %s from %s", target, msg.SenderID)
+		return
+	}
+
+	if target[:3] != Config.SID {
+		for sid := range IterFor([]string{target}, msg.SenderID) {
+			fmsg := msg.Dup()
+			fmsg.DestIDs = []string{sid}
+			ircd.ToServer <- fmsg
+		}
+		return
+	}
+
+	channel, err := GetChannel(name, false)
+	if err != nil {
+		Warn.Printf("INVITE for unknown channel %s from %s", name, msg.SenderID)
+		return
+	}
+	if len(msg.Args) > 2 && msg.Args[2] != channel.TS() {
+		Debug.Printf("Ignoring INVITE for %s with TS %s", name, msg.Args[2])
+		return
+	}
+
+	channel.Invite(target)
+	ircd.ToClient <- &Message{
+		Prefix:  msg.Prefix,
+		Command: CMD_INVITE,
+		Args: []string{
+			target,
+			channel.Name(),
+		},
+		DestIDs: []string{target},
+	}
+}
@@ -0,0 +1,111 @@
+package ircd
+
+import (
+	"strings"
+)
+
+var (
+	killhooks = []*Hook{
+		Register(CMD_KILL, EMASK_USER, OptArgs(1, 1), Kill),
+		Register(CMD_KILL, EMASK_SERVER, OptArgs(1, 1), SKill),
+	}
+)
+
+// killUser removes the victim from the network.  The killer is the nick or
+// server name shown in the quit message.
+func killUser(victim, killer, reason string, ircd *IRCd) {
+	quit := "Killed (" + killer + " (" + reason + "))"
+	removeUser(victim, quit, "Closing Link ("+quit+")", ircd)
+}
+
+// splitKillPath splits the argument of a KILL into the path the KILL has
+// traveled and the reason.
+//
+//	<path> (<reason>)
+func splitKillPath(arg string) (path, reason string) {
+	pieces := strings.SplitN(arg, " ", 2)
+	if len(pieces) == 1 {
+		return pieces[0], "<No reason given>"
+	}
+	path, reason = pieces[0], pieces[1]
+	if strings.HasPrefix(reason, "(") && strings.HasSuffix(reason, ")") {
+		reason = reason[1 : len(reason)-1]
+	}
+	return path, reason
+}
+
+// Handle a KILL from a local operator.
+//
+//	KILL <nick> [:<reason>]
+func Kill(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	u := GetUser(uid)
+	if !u.HasMode('o') {
+		ircd.ToClient <- NewNumeric(ERR_NOPRIVILEGES).Message(uid)
+		return
+	}
+
+	victim, err := GetID(msg.Args[0])
+	if num, ok := err.(*Numeric); ok {
+		ircd.ToClient <- num.Message(uid)
+		return
+	}
+
+	nick := u.Nick()
+	reason := nick
+	if len(msg.Args) > 1 && len(msg.Args[1]) > 0 {
+		reason = msg.Args[1]
+	}
+	path := Config.Name + "!" + u.Host() + "!" + u.User() + "!" + nick
+
+	for sid := range ServerIter() {
+		ircd.ToServer <- &Message{
+			Prefix:  uid,
+			Command: CMD_KILL,
+			Args: []string{
+				victim,
+				path + " (" + reason + ")",
+			},
+			DestIDs: []string{sid},
+		}
+	}
+
+	killUser(victim, nick, reason, ircd)
+}
+
+// Handle a KILL from a linked server.  Our name is added to the path before
+// the KILL is passed on.
+//
+//	:<uid|sid> KILL <uid> :<path> (<reason>)
+func SKill(hook string, msg *Message, ircd *IRCd) {
+	victim := msg.Args[0]
+	if _, _, _, _, ok := GetUserInfo(victim); !ok {
+		Warn.Printf("KILL for unknown user %s from %s", victim, msg.SenderID)
+		return
+	}
+
+	path, reason := sourceName(msg.SenderID), "<No reason given>"
+	if len(msg.Args) > 1 {
+		path, reason = splitKillPath(msg.Args[1])
+	}
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			ircd.ToServer <- &Message{
+				Prefix:  msg.Prefix,
+				Command: CMD_KILL,
+				Args: []string{
+					victim,
+					Config.Name + "!" + path + " (" + reason + ")",
+				},
+				DestIDs: []string{sid},
+			}
+		}
+	}
+
+	killer := sourceName(msg.Prefix)
+	if len(msg.Prefix) != 3 {
+		killer, _, _, _, _ = GetUserInfo(msg.Prefix)
+	}
+	killUser(victim, killer, reason, ircd)
+}
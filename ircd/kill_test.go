@@ -0,0 +1,127 @@
+package ircd
+
+import (
+	"testing"
+)
+
+var killPathTests = []struct {
+	Arg    string
+	Path   string
+	Reason string
+}{
+	{"a.server!host!user!nick (spamming)", "a.server!host!user!nick", "spamming"},
+	{"a.server!nick (two words)", "a.server!nick", "two words"},
+	{"a.server!nick unbracketed reason", "a.server!nick", "unbracketed reason"},
+	{"a.server!nick", "a.server!nick", "<No reason given>"},
+}
+
+func TestSplitKillPath(t *testing.T) {
+	for idx, test := range killPathTests {
+		path, reason := splitKillPath(test.Arg)
+		if path != test.Path || reason != test.Reason {
+			t.Errorf("#%d: splitKillPath(%q) = %q, %q; want %q, %q",
+				idx, test.Arg, path, reason, test.Path, test.Reason)
+		}
+	}
+}
+
+var killTests = []handlerTest{
+	{
+		Desc:   "kill without privileges",
+		Hook:   CMD_KILL,
+		Func:   Kill,
+		Sender: "bob",
+		Args:   []string{"alice", "go away"},
+		ToClient: []string{
+			"bob 481 * :Permission Denied- You're not an IRC operator",
+		},
+	},
+	{
+		Desc:   "kill an unknown nick",
+		Hook:   CMD_KILL,
+		Func:   Kill,
+		Sender: "olive",
+		Args:   []string{"nobody", "go away"},
+		ToClient: []string{
+			"olive 401 * nobody :No such nick/channel",
+		},
+	},
+	{
+		Desc:   "kill a local user",
+		Hook:   CMD_KILL,
+		Func:   Kill,
+		Sender: "olive",
+		Args:   []string{"alice", "go away"},
+		ToClient: []string{
+			"bob,dave :alice QUIT :Killed (olive (go away))",
+			"alice ERROR :Closing Link (Killed (olive (go away)))",
+		},
+		ToServer: []string{
+			"1TA :olive KILL alice :blight.local!olive.example!olive!olive (go away)",
+			"1TB :olive KILL alice :blight.local!olive.example!olive!olive (go away)",
+		},
+		Unsorted: true,
+	},
+	{
+		Desc:   "remote kill of a local user",
+		Hook:   CMD_KILL,
+		Func:   SKill,
+		Sender: "1TA",
+		Prefix: "carol",
+		Args:   []string{"dave", "1ta.test!carol.example!carol!carol (flooding)"},
+		ToClient: []string{
+			"bob :dave QUIT :Killed (carol (flooding))",
+			"dave ERROR :Closing Link (Killed (carol (flooding)))",
+		},
+		ToServer: []string{
+			"1TB :carol KILL dave :blight.local!1ta.test!carol.example!carol!carol (flooding)",
+		},
+		Unsorted: true,
+	},
+	{
+		Desc:   "remote kill by a server without a reason",
+		Hook:   CMD_KILL,
+		Func:   SKill,
+		Sender: "1TA",
+		Prefix: "1TA",
+		Args:   []string{"erin"},
+		ToClient: []string{
+			"bob :erin QUIT :Killed (1ta.test (<No reason given>))",
+			"erin ERROR :Closing Link (Killed (1ta.test (<No reason given>)))",
+		},
+		ToServer: []string{
+			"1TB :1TA KILL erin :blight.local!1ta.test (<No reason given>)",
+		},
+		Unsorted: true,
+	},
+	{
+		Desc:   "remote kill of an unknown user",
+		Hook:   CMD_KILL,
+		Func:   SKill,
+		Sender: "1TA",
+		Prefix: "carol",
+		Args:   []string{"1TAAAAAAA", "gone"},
+	},
+}
+
+func TestKill(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	testLink(t, "1TB")
+	olive := testUser(t, Config.SID, "olive")
+	changes, _ := ParseModeChange([]string{"+o"}, UserModes)
+	GetUser(olive).ApplyModes(changes)
+	bob := testUser(t, Config.SID, "bob")
+
+	channel, _ := GetChannel("#kill", true)
+	for _, nick := range []string{"alice", "dave"} {
+		channel.Join(testUser(t, Config.SID, nick))
+	}
+	channel.Join(testUser(t, "1TA", "erin"))
+	channel.Join(bob)
+	testUser(t, "1TA", "carol")
+
+	for _, test := range killTests {
+		test.run(t)
+	}
+}
@@ -0,0 +1,141 @@
+package ircd
+
+import (
+	"sync"
+)
+
+// Clients which have enabled labeled-response may tag a command with a
+// label, which is sent back with the reply.  Several replies are sent in a
+// labeled-response batch, and a command with no reply is acknowledged with
+// ACK.
+var (
+	capLabeledResponse = RegisterCap("labeled-response", "")
+	tagLabel           = RegisterTag("label", capLabeledResponse)
+)
+
+// The longest label which is sent back to the client.
+const MaxLabelLength = 64
+
+// A labeledResponse collects the replies which the hooks called for a
+// labeled command send to its sender, so that they may be labeled once every
+// hook has returned.  The hooks are given a copy of the server whose
+// ToClient leads to the labeledResponse.
+type labeledResponse struct {
+	uid     string
+	label   string
+	hook    string
+	msg     *Message
+	ircd    *IRCd // the server to which the replies are passed on
+	local   *IRCd // the server given to the hooks
+	replies chan *Message
+	pending *sync.WaitGroup
+}
+
+// labelReplies returns a labeledResponse for the message from a local
+// client, or nil if the message has no label or the client has not enabled
+// labeled-response.
+func labelReplies(hook string, msg *Message, ircd *IRCd) *labeledResponse {
+	label, ok := msg.Tags[tagLabel]
+	if !ok || len(label) == 0 || len(label) > MaxLabelLength {
+		return nil
+	}
+	if !GetUser(msg.SenderID).HasCap(capLabeledResponse) {
+		return nil
+	}
+	r := &labeledResponse{
+		uid:     msg.SenderID,
+		label:   label,
+		hook:    hook,
+		msg:     msg,
+		ircd:    ircd,
+		replies: make(chan *Message),
+		pending: new(sync.WaitGroup),
+	}
+	local := *ircd
+	local.ToClient = r.replies
+	r.local = &local
+	go r.collect()
+	return r
+}
+
+// call calls the hook function in a new goroutine.
+func (r *labeledResponse) call(fn func(string, *Message, *IRCd)) {
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		fn(r.hook, r.msg, r.local)
+	}()
+}
+
+// finish sends the replies once every hook which was called has returned.
+func (r *labeledResponse) finish() {
+	go func() {
+		r.pending.Wait()
+		close(r.replies)
+	}()
+}
+
+// collect passes on messages to other clients as they are sent and holds
+// the replies to the sender until the hooks have returned.  If the sender is
+// deleted, the replies held so far are sent first.
+func (r *labeledResponse) collect() {
+	var held []*Message
+	sent := false
+	for msg := range r.replies {
+		mine := false
+		others := make([]string, 0, len(msg.DestIDs))
+		for _, id := range msg.DestIDs {
+			if id == r.uid {
+				mine = true
+			} else {
+				others = append(others, id)
+			}
+		}
+		if sent || !mine {
+			r.ircd.ToClient <- msg
+			continue
+		}
+		if msg.Command == INT_DELUSER {
+			r.send(held)
+			sent = true
+			r.ircd.ToClient <- msg
+			continue
+		}
+
+		reply := msg.Dup()
+		reply.DestIDs = []string{r.uid}
+		held = append(held, reply)
+		if len(others) > 0 {
+			msg.DestIDs = others
+			r.ircd.ToClient <- msg
+		}
+	}
+	if !sent {
+		r.send(held)
+	}
+}
+
+// send sends the replies to the sender with the label.
+func (r *labeledResponse) send(replies []*Message) {
+	switch len(replies) {
+	case 0:
+		r.ircd.ToClient <- &Message{
+			Tags: map[string]string{
+				tagLabel: r.label,
+			},
+			Command: CMD_ACK,
+			DestIDs: []string{r.uid},
+		}
+	case 1:
+		reply := replies[0]
+		if reply.Tags == nil {
+			reply.Tags = make(map[string]string)
+		}
+		reply.Tags[tagLabel] = r.label
+		r.ircd.ToClient <- reply
+	default:
+		sendBatch(r.uid, "labeled-response", nil, map[string]string{
+			tagLabel: r.label,
+		}, replies, r.ircd)
+	}
+}
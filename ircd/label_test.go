@@ -0,0 +1,103 @@
+package ircd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var labelTests = []struct {
+	Desc    string
+	Replies int
+	Other   bool
+	Expect  []string
+}{
+	{
+		Desc:   "no reply",
+		Expect: []string{"@label=abc ACK"},
+	},
+	{
+		Desc:    "one reply",
+		Replies: 1,
+		Expect:  []string{"@label=abc 0"},
+	},
+	{
+		Desc:    "reply shared with another user",
+		Replies: 1,
+		Other:   true,
+		Expect:  []string{"other 0", "@label=abc 0"},
+	},
+	{
+		Desc:    "several replies",
+		Replies: 2,
+		Expect: []string{
+			"@label=abc BATCH +ref labeled-response",
+			"@batch=ref 0",
+			"@batch=ref 1",
+			"BATCH -ref",
+		},
+	},
+}
+
+func TestLabeledResponse(t *testing.T) {
+	uid, other := NextUserID(), NextUserID()
+	GetUser(uid).SetCaps([]string{capLabeledResponse, capBatch}, nil)
+
+	msg := &Message{
+		Tags:     map[string]string{tagLabel: "abc"},
+		SenderID: other,
+		Command:  "TEST",
+	}
+	if r := labelReplies("TEST", msg, &IRCd{}); r != nil {
+		t.Errorf("labelReplies without labeled-response = %v, want nil", r)
+	}
+
+	for _, test := range labelTests {
+		ircd := &IRCd{ToClient: make(chan *Message, 10)}
+		msg.SenderID = uid
+		r := labelReplies("TEST", msg, ircd)
+		if r == nil {
+			t.Fatalf("%s: labelReplies = nil", test.Desc)
+		}
+		r.call(func(hook string, msg *Message, ircd *IRCd) {
+			for i := 0; i < test.Replies; i++ {
+				dest := []string{uid}
+				if test.Other {
+					dest = append([]string{other}, dest...)
+				}
+				ircd.ToClient <- &Message{
+					Command: string(rune('0' + i)),
+					DestIDs: dest,
+				}
+			}
+		})
+		r.finish()
+
+		ref := ""
+		for i, want := range test.Expect {
+			var got *Message
+			select {
+			case got = <-ircd.ToClient:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: #%d: timed out waiting for %q", test.Desc, i, want)
+			}
+			if got.Command == CMD_BATCH && len(ref) == 0 {
+				ref = got.Args[0][1:]
+			}
+			line := strings.TrimSuffix(got.String(), "\r\n")
+			if len(ref) > 0 {
+				line = strings.Replace(line, ref, "ref", -1)
+			}
+			dest := uid
+			if strings.HasPrefix(want, "other ") {
+				want, dest = strings.TrimPrefix(want, "other "), other
+			}
+			if len(got.DestIDs) != 1 || got.DestIDs[0] != dest {
+				t.Errorf("%s: #%d: sent to %v, want %s", test.Desc, i, got.DestIDs, dest)
+			}
+			if line != want {
+				t.Errorf("%s: #%d: got %q, want %q", test.Desc, i, line, want)
+			}
+		}
+	}
+}
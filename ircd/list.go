@@ -0,0 +1,284 @@
+package ircd
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	listhooks = []*Hook{
+		Register(CMD_NAMES, EMASK_USER, OptArgs(0, 2), Names),
+		Register(CMD_LIST, EMASK_USER, OptArgs(0, 2), List),
+	}
+)
+
+// joinNames joins the names with spaces into as few lines as possible without
+// any line exceeding max bytes.  A name longer than max is put on a line by
+// itself.
+func joinNames(names []string, max int) []string {
+	lines := []string{}
+	line := ""
+	for _, name := range names {
+		if len(line) > 0 && len(line)+1+len(name) > max {
+			lines = append(lines, line)
+			line = ""
+		}
+		if len(line) > 0 {
+			line += " "
+		}
+		line += name
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// namesSpace returns the number of bytes left for names in an RPL_NAMREPLY
+// sent to the nick.
+func namesSpace(nick, typ, channel string) int {
+	// :<server> 353 <nick> <type> <channel> :<names>\r\n
+	used := 1 + len(Config.Name) + 1 + len(RPL_NAMREPLY) + 1 + len(nick) +
+		1 + len(typ) + 1 + len(channel) + 2 + 2
+	return MaxLineLength - used
+}
+
+// Handle a NAMES from a local client.  Without a channel, every visible
+// channel is listed, followed by the visible users who are on none of them.
+//
+//	NAMES [<channel>{,<channel>} [<target>]]
+func Names(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+
+	if len(msg.Args) > 0 {
+		for _, name := range strings.Split(msg.Args[0], ",") {
+			channel, err := GetChannel(name, false)
+			if err == nil && channel.Visible(uid) {
+				for _, msg := range channel.NamesMessages(uid) {
+					ircd.ToClient <- msg
+				}
+			}
+			ircd.ToClient <- NewNumeric(RPL_ENDOFNAMES, name).Message(uid)
+		}
+		return
+	}
+
+	listed := map[string]bool{}
+	for name := range ChannelIter() {
+		channel, err := GetChannel(name, false)
+		if err != nil || !channel.Visible(uid) {
+			continue
+		}
+		for _, id := range channel.UserIDs() {
+			listed[id] = true
+		}
+		for _, msg := range channel.NamesMessages(uid) {
+			ircd.ToClient <- msg
+		}
+	}
+
+	names := []string{}
+	for id := range UserIter() {
+		nick, _, _, typ, ok := GetUserInfo(id)
+		if !ok || typ != RegisteredAsUser || listed[id] {
+			continue
+		}
+		if id != uid && GetUser(id).HasMode('i') {
+			continue
+		}
+		names = append(names, nick)
+	}
+	sort.Strings(names)
+
+	nick, _, _, _, _ := GetUserInfo(uid)
+	for _, line := range joinNames(names, namesSpace(nick, "*", "*")) {
+		ircd.ToClient <- &Message{
+			Command: RPL_NAMREPLY,
+			Args: []string{
+				"*",
+				"*",
+				"*",
+				line,
+			},
+			DestIDs: []string{uid},
+		}
+	}
+	ircd.ToClient <- NewNumeric(RPL_ENDOFNAMES, "*").Message(uid)
+}
+
+// A listFilter selects the channels included in the reply to a LIST.  The
+// filters are those advertised by ELIST in RPL_ISUPPORT:
+//
+//	>n, <n      more than or fewer than n users
+//	C>n, C<n    created more than or less than n minutes ago
+//	T>n, T<n    topic set more than or less than n minutes ago
+//	mask, !mask channel names matching or not matching the mask
+//
+// Channel names without wildcards select only the named channels.
+type listFilter struct {
+	names    []string
+	masks    []string
+	notMasks []string
+
+	minUsers, maxUsers int
+
+	createdBefore, createdAfter time.Time // zero is unrestricted
+	topicBefore, topicAfter     time.Time
+}
+
+// parseListFilter parses the comma-separated filters given to a LIST.  Times
+// are relative to now.
+func parseListFilter(arg string, now time.Time) *listFilter {
+	f := &listFilter{
+		maxUsers: math.MaxInt32,
+	}
+	for _, tok := range strings.Split(arg, ",") {
+		var before, after *time.Time
+		cond := tok
+		switch {
+		case strings.HasPrefix(tok, "C"):
+			before, after, cond = &f.createdBefore, &f.createdAfter, tok[1:]
+		case strings.HasPrefix(tok, "T"):
+			before, after, cond = &f.topicBefore, &f.topicAfter, tok[1:]
+		}
+
+		if len(cond) > 1 && (cond[0] == '<' || cond[0] == '>') {
+			n, err := strconv.Atoi(cond[1:])
+			if err == nil && n >= 0 {
+				ago := now.Add(-time.Duration(n) * time.Minute)
+				switch {
+				case before == nil && cond[0] == '>':
+					f.minUsers = n + 1
+				case before == nil:
+					f.maxUsers = n - 1
+				case cond[0] == '>':
+					*before = ago
+				default:
+					*after = ago
+				}
+				continue
+			}
+		}
+
+		switch {
+		case len(tok) == 0:
+		case tok[0] == '!':
+			f.notMasks = append(f.notMasks, ToLower(tok[1:]))
+		case strings.ContainsAny(tok, "*?"):
+			f.masks = append(f.masks, ToLower(tok))
+		default:
+			f.names = append(f.names, ToLower(tok))
+		}
+	}
+	return f
+}
+
+// match returns true if the channel passes the filter.  The topic time is
+// zero if no topic is set.
+func (f *listFilter) match(name string, users int, created, topicTS time.Time) bool {
+	lowname := ToLower(name)
+	if len(f.names) > 0 {
+		found := false
+		for _, n := range f.names {
+			if n == lowname {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, mask := range f.masks {
+		if match, _ := filepath.Match(mask, lowname); !match {
+			return false
+		}
+	}
+	for _, mask := range f.notMasks {
+		if match, _ := filepath.Match(mask, lowname); match {
+			return false
+		}
+	}
+
+	if users < f.minUsers || users > f.maxUsers {
+		return false
+	}
+
+	if !f.createdBefore.IsZero() && !created.Before(f.createdBefore) {
+		return false
+	}
+	if !f.createdAfter.IsZero() && !created.After(f.createdAfter) {
+		return false
+	}
+	if !f.topicBefore.IsZero() && (topicTS.IsZero() || !topicTS.Before(f.topicBefore)) {
+		return false
+	}
+	if !f.topicAfter.IsZero() && (topicTS.IsZero() || !topicTS.After(f.topicAfter)) {
+		return false
+	}
+	return true
+}
+
+// unixTime converts a TS to a time.  An empty or invalid TS gives the zero
+// time.
+func unixTime(ts string) time.Time {
+	its, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(its, 0)
+}
+
+// Handle a LIST from a local client.  Secret and private channels are only
+// listed to their members.
+//
+//	LIST [<channel>{,<channel>}|<filter>{,<filter>} [<target>]]
+func List(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	filter := parseListFilter("", time.Now())
+	if len(msg.Args) > 0 {
+		filter = parseListFilter(msg.Args[0], time.Now())
+	}
+
+	ircd.ToClient <- &Message{
+		Command: RPL_LISTSTART,
+		Args: []string{
+			"*",
+			"Channel",
+			"Users  Name",
+		},
+		DestIDs: []string{uid},
+	}
+
+	names := []string{}
+	for name := range ChannelIter() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		channel, err := GetChannel(name, false)
+		if err != nil || !channel.Visible(uid) {
+			continue
+		}
+		users := len(channel.UserIDs())
+		topic, _, ts := channel.Topic()
+		topicTS := time.Time{}
+		if len(topic) > 0 {
+			topicTS = unixTime(ts)
+		}
+		if !filter.match(name, users, unixTime(channel.TS()), topicTS) {
+			continue
+		}
+
+		msg := NewNumeric(RPL_LIST, name, strconv.Itoa(users)).Message(uid)
+		msg.Args[3] = topic
+		ircd.ToClient <- msg
+	}
+
+	ircd.ToClient <- NewNumeric(RPL_LISTEND).Message(uid)
+}
@@ -0,0 +1,81 @@
+package ircd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var joinNamesTests = []struct {
+	Names []string
+	Max   int
+	Lines []string
+}{
+	{nil, 10, []string{}},
+	{[]string{"a", "b", "c"}, 10, []string{"a b c"}},
+	{[]string{"abcd", "efgh", "ijkl"}, 9, []string{"abcd efgh", "ijkl"}},
+	{[]string{"abcd", "efgh", "ijkl"}, 8, []string{"abcd", "efgh", "ijkl"}},
+	{[]string{"abcdefghijk", "l"}, 8, []string{"abcdefghijk", "l"}},
+}
+
+func TestJoinNames(t *testing.T) {
+	for idx, test := range joinNamesTests {
+		got := joinNames(test.Names, test.Max)
+		if g, w := strings.Join(got, "|"), strings.Join(test.Lines, "|"); g != w {
+			t.Errorf("#%d: joinNames(%q, %d) = %q, want %q", idx, test.Names, test.Max, g, w)
+		}
+	}
+}
+
+var listFilterTests = []struct {
+	Filter  string
+	Name    string
+	Users   int
+	Created int // minutes ago
+	Topic   int // minutes ago, or -1 for no topic
+	Match   bool
+}{
+	{"", "#any", 1, 0, -1, true},
+	{"#any", "#ANY", 1, 0, -1, true},
+	{"#any", "#other", 1, 0, -1, false},
+	{"#a*", "#abc", 1, 0, -1, true},
+	{"#a*", "#bcd", 1, 0, -1, false},
+	{"!#a*", "#abc", 1, 0, -1, false},
+	{"!#a*", "#bcd", 1, 0, -1, true},
+	{">2", "#a", 2, 0, -1, false},
+	{">2", "#a", 3, 0, -1, true},
+	{"<2", "#a", 2, 0, -1, false},
+	{"<2", "#a", 1, 0, -1, true},
+	{"<1", "#a", 0, 0, -1, true},
+	{"<0", "#a", 0, 0, -1, false},
+	{"<0", "#a", 1, 0, -1, false},
+	{">1,<4", "#a", 3, 0, -1, true},
+	{">1,<4", "#a", 4, 0, -1, false},
+	{"C>10", "#a", 1, 5, -1, false},
+	{"C>10", "#a", 1, 15, -1, true},
+	{"C<10", "#a", 1, 5, -1, true},
+	{"C<10", "#a", 1, 15, -1, false},
+	{"T<10", "#a", 1, 60, 5, true},
+	{"T<10", "#a", 1, 60, -1, false},
+	{"T>10", "#a", 1, 60, 5, false},
+	{"T>10", "#a", 1, 60, 15, true},
+	{"#a*,>1,C>10", "#abc", 2, 15, -1, true},
+	{"#a*,>1,C>10", "#abc", 1, 15, -1, false},
+}
+
+func TestListFilter(t *testing.T) {
+	now := time.Now()
+	ago := func(min int) time.Time {
+		return now.Add(-time.Duration(min) * time.Minute)
+	}
+	for idx, test := range listFilterTests {
+		f := parseListFilter(test.Filter, now)
+		topicTS := time.Time{}
+		if test.Topic >= 0 {
+			topicTS = ago(test.Topic)
+		}
+		if got, want := f.match(test.Name, test.Users, ago(test.Created), topicTS), test.Match; got != want {
+			t.Errorf("#%d: %q matches %s = %v, want %v", idx, test.Filter, test.Name, got, want)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package ircd
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"sync"
 )
 
 type Listener struct {
+	mutex    *sync.Mutex // guards ports, which the serving goroutines change
 	ports    map[int]net.Listener
 	Incoming chan *Conn
 	wg       sync.WaitGroup
@@ -14,24 +16,92 @@ type Listener struct {
 
 func NewListener() *Listener {
 	l := new(Listener)
+	l.mutex = new(sync.Mutex)
 	l.ports = make(map[int]net.Listener)
 	l.Incoming = make(chan *Conn)
 	return l
 }
 
+// listener returns the listener for the given port number, if there is one.
+func (l *Listener) listener(portno int) (listener net.Listener, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	listener, ok = l.ports[portno]
+	return
+}
+
+// portCount returns the number of ports being listened to.
+func (l *Listener) portCount() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.ports)
+}
+
 // AddPort starts a new goroutine listening on the given port number.
 // If the port number is already being listened to, nothing happens.
 func (l *Listener) AddPort(portno int) {
-	if _, ok := l.ports[portno]; ok {
+	if _, ok := l.listener(portno); ok {
 		return
 	}
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", portno))
 	if err != nil {
-		fmt.Sprintf("Error[%d]: %s\n", portno, err)
+		Warn.Printf("Error[%d]: %s", portno, err)
+		return
+	}
+	l.serve(portno, listener, nil)
+}
+
+// AddSSLPort is like AddPort, but connections to the port are encrypted
+// using the given TLS configuration.
+func (l *Listener) AddSSLPort(portno int, config *tls.Config) {
+	if _, ok := l.listener(portno); ok {
+		return
+	}
+	listener, err := tls.Listen("tcp", fmt.Sprintf(":%d", portno), config)
+	if err != nil {
+		Warn.Printf("Error[%d]: %s", portno, err)
+		return
+	}
+	l.serve(portno, listener, nil)
+}
+
+// AddWebSocketPort is like AddPort (or AddSSLPort, if config is not nil),
+// but clients connect to the port with WebSocket.  Browsers may only connect
+// from origins matching the globs (or from any origin if there are none),
+// and the address of a client connecting through one of the trusted proxies
+// (IP addresses or CIDR ranges) is taken from X-Forwarded-For.
+func (l *Listener) AddWebSocketPort(portno int, config *tls.Config, origins, proxies []string) {
+	if _, ok := l.listener(portno); ok {
+		return
+	}
+	trusted, err := parseNetworks(proxies)
+	if err != nil {
+		Warn.Printf("Error[%d]: %s", portno, err)
 		return
 	}
+	var listener net.Listener
+	if config != nil {
+		listener, err = tls.Listen("tcp", fmt.Sprintf(":%d", portno), config)
+	} else {
+		listener, err = net.Listen("tcp", fmt.Sprintf(":%d", portno))
+	}
+	if err != nil {
+		Warn.Printf("Error[%d]: %s", portno, err)
+		return
+	}
+	ws := &wsUpgrader{
+		origins: origins,
+		proxies: trusted,
+	}
+	l.serve(portno, listener, ws.upgrade)
+}
 
+// serve accepts connections to the port.  If upgrade is not nil, it is
+// called on each connection before it is passed on.
+func (l *Listener) serve(portno int, listener net.Listener, upgrade func(net.Conn) (net.Conn, error)) {
+	l.mutex.Lock()
 	l.ports[portno] = listener
+	l.mutex.Unlock()
 	l.wg.Add(1)
 	go func() {
 		defer listener.Close()
@@ -39,7 +109,7 @@ func (l *Listener) AddPort(portno int) {
 		for {
 			conn, err := listener.Accept()
 			if err != nil {
-				fmt.Sprintf("Error[%d]: %s\n", portno, err)
+				Debug.Printf("Error[%d]: %s", portno, err)
 				break
 			}
 			if listener.Addr() == nil {
@@ -47,17 +117,28 @@ func (l *Listener) AddPort(portno int) {
 				break
 			}
 			go func(c net.Conn) {
+				if upgrade != nil {
+					uc, err := upgrade(c)
+					if err != nil {
+						Debug.Printf("Error[%d]: %s", portno, err)
+						c.Close()
+						return
+					}
+					c = uc
+				}
 				l.Incoming <- NewConn(c)
 			}(conn)
 		}
+		l.mutex.Lock()
 		delete(l.ports, portno)
+		l.mutex.Unlock()
 	}()
 }
 
 // ClosePort stops listening on the given port.  If this listener
 // is not listening on the port, nothing happens.
 func (l *Listener) ClosePort(portno int) {
-	listener, ok := l.ports[portno]
+	listener, ok := l.listener(portno)
 	if !ok {
 		return
 	}
@@ -70,7 +151,14 @@ func (l *Listener) ClosePort(portno int) {
 
 // Close signals all of the listening ports to stop listening.
 func (l *Listener) Close() {
+	l.mutex.Lock()
+	ports := make(map[int]net.Listener, len(l.ports))
 	for port, listener := range l.ports {
+		ports[port] = listener
+	}
+	l.mutex.Unlock()
+
+	for port, listener := range ports {
 		listener.Close()
 		c, _ := net.Dial("tcp", fmt.Sprintf(":%d", port))
 		if c != nil {
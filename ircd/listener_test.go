@@ -1,6 +1,15 @@
 package ircd
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
 	"runtime"
 	"testing"
 	"time"
@@ -21,23 +30,23 @@ func TestAddPort(t *testing.T) {
 	l := NewListener()
 	gcnt := runtime.NumGoroutine()
 	l.AddPort(56561)
-	if 1 != len(l.ports) {
-		t.Errorf("Length of ports array should be 1, got %d", len(l.ports))
+	if 1 != l.portCount() {
+		t.Errorf("Length of ports array should be 1, got %d", l.portCount())
 	}
 	if runtime.Gosched(); gcnt >= runtime.NumGoroutine() {
 		t.Errorf("Expected more than %d goroutines after AddPort, %d running", gcnt, runtime.NumGoroutine())
 	}
-	if listener, ok := l.ports[56561]; ok {
+	if listener, ok := l.listener(56561); ok {
 		if listener == nil {
 			t.Errorf("Port listener should not be nil")
 		}
 	} else {
-		t.Errorf("Listener should have entry for port 56561, got %v", l.ports)
+		t.Errorf("Listener should have entry for port 56561, got %d ports", l.portCount())
 	}
 	gcnt = runtime.NumGoroutine()
 	l.Close()
-	if 0 != len(l.ports) {
-		t.Errorf("After Close(), ports should have 0 entries, got %d", len(l.ports))
+	if 0 != l.portCount() {
+		t.Errorf("After Close(), ports should have 0 entries, got %d", l.portCount())
 	}
 	if runtime.Gosched(); gcnt <= runtime.NumGoroutine() {
 		t.Errorf("Expected fewer than %d goroutines after Close(), %d running", gcnt, runtime.NumGoroutine())
@@ -50,20 +59,82 @@ func TestClosePort(t *testing.T) {
 	gcnt := runtime.NumGoroutine()
 	l.ClosePort(56561)
 	// ClosePort is not synchronized, so give it some time (on mac, dialog pops up)
-	for i := 0; i < 100 && 0 != len(l.ports); i++ {
+	for i := 0; i < 100 && 0 != l.portCount(); i++ {
 		time.Sleep(1e6)
 	}
-	if runtime.Gosched(); 0 != len(l.ports) {
-		t.Errorf("After ClosePort(), ports should have 0 entries, got %d", len(l.ports))
+	if runtime.Gosched(); 0 != l.portCount() {
+		t.Errorf("After ClosePort(), ports should have 0 entries, got %d", l.portCount())
 	}
 	if runtime.Gosched(); gcnt <= runtime.NumGoroutine() {
 		t.Errorf("Expected fewer than %d goroutines after ClosePort(), %d running", gcnt, runtime.NumGoroutine())
 	}
 	l.Close()
-	if 0 != len(l.ports) {
-		t.Errorf("After Close(), ports should have 0 entries, got %d", len(l.ports))
+	if 0 != l.portCount() {
+		t.Errorf("After Close(), ports should have 0 entries, got %d", l.portCount())
 	}
 	if runtime.Gosched(); gcnt <= runtime.NumGoroutine() {
 		t.Errorf("Expected fewer than %d goroutines after Close(), %d running", gcnt, runtime.NumGoroutine())
 	}
 }
+
+func testCertificate(t *testing.T, name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %s", err)
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
+
+func TestAddSSLPort(t *testing.T) {
+	server, client := testCertificate(t, "server"), testCertificate(t, "client")
+
+	l := NewListener()
+	defer l.Close()
+	l.AddSSLPort(56562, &tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientAuth:   tls.RequestClientCert,
+	})
+
+	// The handshake does not complete until the server starts reading
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		c, err := tls.Dial("tcp", "localhost:56562", &tls.Config{
+			Certificates:       []tls.Certificate{client},
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			t.Errorf("Dial: %s", err)
+			return
+		}
+		defer c.Close()
+		c.Write([]byte("NICK test\r\n"))
+		<-done
+	}()
+
+	conn := <-l.Incoming
+	messages := make(chan *Message)
+	conn.Subscribe(messages)
+	<-messages
+
+	if !conn.TLS() {
+		t.Errorf("TLS() = false, want true")
+	}
+	sum := sha256.Sum256(client.Certificate[0])
+	if got, want := conn.Fingerprint(), hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("Fingerprint() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,348 @@
+package ircd
+
+import (
+	"strings"
+)
+
+var (
+	chanhooks = []*Hook{
+		Register(CMD_JOIN, EMASK_USER, OptArgs(1, 1), Join),
+		Register(CMD_PART, EMASK_USER, OptArgs(1, 1), Part),
+		Register(CMD_JOIN, EMASK_SERVER, OptArgs(1, 2), SJoin),
+		Register(CMD_SJOIN, EMASK_SERVER, MinArgs(4), SJoin),
+		Register(CMD_PART, EMASK_SERVER, OptArgs(1, 1), Part),
+	}
+)
+
+// Clients which have enabled extended-join are told the account and real
+// name of users who join their channels.
+var capExtendedJoin = RegisterCap("extended-join", "")
+
+// localIDs returns the IDs in the list which belong to users on this server.
+func localIDs(ids []string) []string {
+	local := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id[:3] == Config.SID {
+			local = append(local, id)
+		}
+	}
+	return local
+}
+
+// localPeers returns the IDs of the local users (other than the user) who
+// share a channel with the user.
+func localPeers(uid string) []string {
+	seen := map[string]bool{uid: true}
+	peers := []string{}
+	for _, channel := range UserChannels(uid) {
+		for _, id := range localIDs(channel.UserIDs()) {
+			if !seen[id] {
+				seen[id] = true
+				peers = append(peers, id)
+			}
+		}
+	}
+	return peers
+}
+
+// sendJoin notifies the local users that the user has joined the channel.
+// Those who have enabled away-notify are also told if the user is away.
+func sendJoin(uid string, channel *Channel, local []string, ircd *IRCd) {
+	u := GetUser(uid)
+	extended, plain := splitCapUsers(local, capExtendedJoin)
+	if len(plain) > 0 {
+		ircd.ToClient <- &Message{
+			Prefix:  uid,
+			Command: CMD_JOIN,
+			Args: []string{
+				channel.Name(),
+			},
+			DestIDs: plain,
+		}
+	}
+	if len(extended) > 0 {
+		account := u.Account()
+		if len(account) == 0 {
+			account = "*"
+		}
+		ircd.ToClient <- &Message{
+			Prefix:  uid,
+			Command: CMD_JOIN,
+			Args: []string{
+				channel.Name(),
+				account,
+				u.Name(),
+			},
+			DestIDs: extended,
+		}
+	}
+
+	if away := u.Away(); len(away) > 0 {
+		others := []string{}
+		for _, id := range local {
+			if id != uid {
+				others = append(others, id)
+			}
+		}
+		if notify, _ := splitCapUsers(others, capAwayNotify); len(notify) > 0 {
+			ircd.ToClient <- &Message{
+				Prefix:  uid,
+				Command: CMD_AWAY,
+				Args: []string{
+					away,
+				},
+				DestIDs: notify,
+			}
+		}
+	}
+}
+
+// Handle a JOIN from a local client.
+//
+//	JOIN <channel>{,<channel>} [<key>{,<key>}]
+//	JOIN 0
+func Join(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+
+	if msg.Args[0] == "0" {
+		partAll(uid, ircd)
+		for sid := range ServerIter() {
+			ircd.ToServer <- &Message{
+				Prefix:  uid,
+				Command: CMD_JOIN,
+				Args:    []string{"0"},
+				DestIDs: []string{sid},
+			}
+		}
+		return
+	}
+
+	channels := strings.Split(msg.Args[0], ",")
+	keys := []string{}
+	if len(msg.Args) > 1 {
+		keys = strings.Split(msg.Args[1], ",")
+	}
+
+	for i, name := range channels {
+		key := ""
+		if i < len(keys) {
+			key = keys[i]
+		}
+		joinChannel(uid, name, key, ircd)
+	}
+}
+
+// joinChannel joins a local user to a single channel, notifies the local
+// members, sends the channel information to the user, and notifies the
+// rest of the network.
+func joinChannel(uid, name, key string, ircd *IRCd) {
+	channel, err := GetChannel(name, true)
+	var notify []string
+	if err == nil {
+		notify, err = channel.JoinWithKey(uid, key)
+	}
+	if err != nil {
+		// Joining a channel you're already on is silently ignored
+		if num, ok := err.(*Numeric); ok && num.num != ERR_USERONCHANNEL {
+			ircd.ToClient <- num.Message(uid)
+		}
+		return
+	}
+
+	// The first user in a channel creates it and becomes an operator
+	created := len(notify) == 1
+	if created {
+		changes, _ := ParseModeChange([]string{DefaultChannelModes}, ChannelModes)
+		changes = append(changes, Mode{
+			Spec: ChannelModes['o'],
+			Op:   SetMode,
+			Args: []string{uid},
+		})
+		channel.ApplyModes(changes)
+	}
+
+	sendJoin(uid, channel, localIDs(notify), ircd)
+
+	sendTopic(uid, channel, false, ircd)
+	for _, msg := range channel.NamesMessages(uid) {
+		ircd.ToClient <- msg
+	}
+	ircd.ToClient <- NewNumeric(RPL_ENDOFNAMES, channel.Name()).Message(uid)
+
+	for sid := range ServerIter() {
+		if created {
+			args := append([]string{channel.TS(), channel.Name()}, channel.Modes()...)
+			ircd.ToServer <- &Message{
+				Prefix:  Config.SID,
+				Command: CMD_SJOIN,
+				Args:    append(args, "@"+uid),
+				DestIDs: []string{sid},
+			}
+			continue
+		}
+		ircd.ToServer <- &Message{
+			Prefix:  uid,
+			Command: CMD_JOIN,
+			Args: []string{
+				channel.TS(),
+				channel.Name(),
+				"+",
+			},
+			DestIDs: []string{sid},
+		}
+	}
+}
+
+// partAll parts the user from all channels and notifies the local members.
+func partAll(uid string, ircd *IRCd) {
+	for name, members := range PartAll(uid) {
+		local := localIDs(members)
+		if len(local) == 0 {
+			continue
+		}
+		ircd.ToClient <- &Message{
+			Prefix:  uid,
+			Command: CMD_PART,
+			Args: []string{
+				name,
+			},
+			DestIDs: local,
+		}
+	}
+}
+
+// Handle a JOIN or SJOIN from a linked server.
+//
+//	:<uid> JOIN <ts> <channel> +
+//	:<uid> JOIN 0
+//	:<sid> SJOIN <ts> <channel> <modes> [<mode params>...] :<members>
+func SJoin(hook string, msg *Message, ircd *IRCd) {
+	var ts, name string
+	var uids, modeArgs []string
+	status := make(map[string]string)
+
+	switch hook {
+	case CMD_JOIN:
+		if msg.Args[0] == "0" {
+			partAll(msg.Prefix, ircd)
+			break
+		}
+		if len(msg.Args) < 2 {
+			Warn.Printf("Malformed JOIN from %s: %s", msg.SenderID, msg)
+			return
+		}
+		ts, name, uids = msg.Args[0], msg.Args[1], []string{msg.Prefix}
+	case CMD_SJOIN:
+		ts, name = msg.Args[0], msg.Args[1]
+		modeArgs = msg.Args[2 : len(msg.Args)-1]
+		for _, member := range strings.Fields(msg.Args[len(msg.Args)-1]) {
+			uid := strings.TrimLeft(member, statusPrefix)
+			uids = append(uids, uid)
+			status[uid] = member[:len(member)-len(uid)]
+		}
+	}
+
+	if len(name) > 0 {
+		channel, err := GetChannel(name, true)
+		if err != nil {
+			Warn.Printf("Bad channel in %s from %s: %s", hook, msg.SenderID, err)
+			return
+		}
+		// If our TS is newer, our modes are discarded.  If their TS is
+		// newer, their modes and statuses are ignored.
+		if channel.SetTS(ts) {
+			notifyModes(msg.SenderID, channel, channel.ResetModes(), ircd)
+		}
+		accept := channel.TS() == ts
+
+		changes := []Mode{}
+		if accept {
+			changes, _ = ParseModeChange(modeArgs, ChannelModes)
+		}
+
+		for _, uid := range uids {
+			notify, err := channel.Join(uid)
+			if err != nil {
+				Warn.Printf("%s %s: %s", hook, channel.Name(), err)
+				continue
+			}
+			sendJoin(uid, channel, localIDs(notify), ircd)
+			if accept {
+				for _, prefix := range status[uid] {
+					idx := strings.IndexRune(statusPrefix, prefix)
+					changes = append(changes, Mode{
+						Spec: ChannelModes[rune(statusMode[idx])],
+						Op:   SetMode,
+						Args: []string{uid},
+					})
+				}
+			}
+		}
+
+		applied, _ := channel.ApplyModes(changes)
+		notifyModes(msg.SenderID, channel, applied, ircd)
+	}
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			Debug.Printf("Forwarding %s from %s to %s", hook, msg.SenderID, sid)
+			fmsg := msg.Dup()
+			fmsg.DestIDs = []string{sid}
+			ircd.ToServer <- fmsg
+		}
+	}
+}
+
+// Handle a PART from a local client or a linked server.
+//
+//	PART <channel>{,<channel>} [:<reason>]
+func Part(hook string, msg *Message, ircd *IRCd) {
+	parter := msg.SenderID
+	if len(msg.SenderID) == 3 {
+		parter = msg.Prefix
+	}
+
+	reason := ""
+	if len(msg.Args) > 1 {
+		reason = msg.Args[1]
+	}
+
+	for _, name := range strings.Split(msg.Args[0], ",") {
+		channel, err := GetChannel(name, false)
+		var notify []string
+		if err == nil {
+			notify, err = channel.Part(parter)
+		}
+		if err != nil {
+			if num, ok := err.(*Numeric); ok && parter == msg.SenderID {
+				ircd.ToClient <- num.Message(parter)
+			} else {
+				Warn.Printf("PART %s from %s: %s", name, msg.SenderID, err)
+			}
+			continue
+		}
+
+		args := []string{channel.Name()}
+		if len(reason) > 0 {
+			args = append(args, reason)
+		}
+		if local := localIDs(notify); len(local) > 0 {
+			ircd.ToClient <- &Message{
+				Prefix:  parter,
+				Command: CMD_PART,
+				Args:    args,
+				DestIDs: local,
+			}
+		}
+		for sid := range ServerIter() {
+			if sid != msg.SenderID {
+				ircd.ToServer <- &Message{
+					Prefix:  parter,
+					Command: CMD_PART,
+					Args:    args,
+					DestIDs: []string{sid},
+				}
+			}
+		}
+	}
+}
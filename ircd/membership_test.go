@@ -0,0 +1,294 @@
+package ircd
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// A handlerTest is a message handled by a hook, and the lines (see
+// sentLines) which it should send to clients and servers.
+type handlerTest struct {
+	Desc     string
+	Hook     string
+	Func     func(string, *Message, *IRCd)
+	Sender   string // the nick of the sending user, or the SID of the link
+	Prefix   string // the nick of the user from whom it was relayed
+	Tags     map[string]string
+	Args     []string
+	ToClient []string
+	ToServer []string
+	Unsorted bool   // the lines may be sent in any order
+	Only     string // if set, only the lines sent to this nick are checked
+}
+
+// run handles the message and checks the lines which were sent.  The nicks
+// in the arguments of a message from a server are replaced by UIDs.  TS in the
+// expected lines stands for the TS of the channel named by the first
+// argument which is a channel.
+func (test handlerTest) run(t *testing.T) {
+	t.Helper()
+	msg := &Message{
+		Tags:     test.Tags,
+		Command:  test.Hook,
+		Args:     test.Args,
+		SenderID: test.Sender,
+	}
+	if id, err := GetID(test.Sender); err == nil {
+		msg.SenderID = id
+	}
+	msg.Prefix = test.Prefix
+	if id, err := GetID(test.Prefix); err == nil {
+		msg.Prefix = id
+	}
+	// Servers name users by their UIDs
+	if len(msg.SenderID) == 3 {
+		msg.Args = make([]string, len(test.Args))
+		for i, arg := range test.Args {
+			msg.Args[i] = testUIDs(arg)
+		}
+	}
+
+	ircd := testIRCd()
+	test.Func(test.Hook, msg, ircd)
+
+	ts := "TS"
+	for _, arg := range test.Args {
+		if channel, err := GetChannel(strings.Split(arg, ",")[0], false); err == nil {
+			ts = channel.TS()
+			break
+		}
+	}
+	fix := func(lines []string) []string {
+		fixed := make([]string, len(lines))
+		for i, line := range lines {
+			fixed[i] = strings.Replace(line, " TS ", " "+ts+" ", -1)
+		}
+		if test.Unsorted {
+			sort.Strings(fixed)
+		}
+		return fixed
+	}
+	check := func(to string, ch chan *Message, want []string) {
+		got := sentLines(ch)
+		if len(test.Only) > 0 {
+			if to != "ToClient" {
+				return
+			}
+			mine := []string{}
+			for _, line := range got {
+				for _, dest := range strings.Split(strings.Fields(line)[0], ",") {
+					if dest == test.Only {
+						mine = append(mine, line)
+					}
+				}
+			}
+			got = mine
+		}
+		if test.Unsorted {
+			sort.Strings(got)
+		}
+		checkLines(t, test.Desc+" "+to, got, fix(want))
+	}
+	check("ToClient", ircd.ToClient, test.ToClient)
+	check("ToServer", ircd.ToServer, test.ToServer)
+}
+
+var joinTests = []handlerTest{
+	{
+		Desc:   "create two channels",
+		Hook:   CMD_JOIN,
+		Func:   Join,
+		Sender: "alice",
+		Args:   []string{"#a,#b"},
+		ToClient: []string{
+			"alice :alice JOIN #a",
+			"alice 353 * = #a @alice",
+			"alice 366 * #a :End of NAMES list",
+			"alice :alice JOIN #b",
+			"alice 353 * = #b @alice",
+			"alice 366 * #b :End of NAMES list",
+		},
+		ToServer: []string{
+			"1TA :000 SJOIN TS #a +nt @alice",
+			"1TA :000 SJOIN TS #b +nt @alice",
+		},
+	},
+	{
+		Desc:   "set a key",
+		Hook:   CMD_MODE,
+		Func:   ModeChange,
+		Sender: "alice",
+		Args:   []string{"#b", "+k", "secret"},
+		ToClient: []string{
+			"alice :alice MODE #b +k secret",
+		},
+		ToServer: []string{
+			"1TA :alice TMODE TS #b +k secret",
+		},
+	},
+	{
+		Desc:   "wrong key",
+		Hook:   CMD_JOIN,
+		Func:   Join,
+		Sender: "bob",
+		Args:   []string{"#b", "wrong"},
+		ToClient: []string{
+			"bob 475 * #b :Cannot join channel (+k)",
+		},
+	},
+	{
+		Desc:   "keys in order",
+		Hook:   CMD_JOIN,
+		Func:   Join,
+		Sender: "bob",
+		Args:   []string{"#b,#a", "secret"},
+		ToClient: []string{
+			"alice,bob :bob JOIN #b",
+			"bob 353 * = #b :@alice bob",
+			"bob 366 * #b :End of NAMES list",
+			"alice,bob :bob JOIN #a",
+			"bob 353 * = #a :@alice bob",
+			"bob 366 * #a :End of NAMES list",
+		},
+		ToServer: []string{
+			"1TA :bob JOIN TS #b +",
+			"1TA :bob JOIN TS #a +",
+		},
+	},
+	{
+		Desc:   "no such channel",
+		Hook:   CMD_JOIN,
+		Func:   Join,
+		Sender: "bob",
+		Args:   []string{"nochannel"},
+		ToClient: []string{
+			"bob 403 * nochannel :No such channel",
+		},
+	},
+	{
+		Desc:   "part with a reason",
+		Hook:   CMD_PART,
+		Func:   Part,
+		Sender: "bob",
+		Args:   []string{"#a,#none", "bye now"},
+		ToClient: []string{
+			"alice,bob :bob PART #a :bye now",
+			"bob 403 * #none :No such channel",
+		},
+		ToServer: []string{
+			"1TA :bob PART #a :bye now",
+		},
+	},
+	{
+		Desc:   "part a channel you are not on",
+		Hook:   CMD_PART,
+		Func:   Part,
+		Sender: "bob",
+		Args:   []string{"#a"},
+		ToClient: []string{
+			"bob 442 * #a :You're not on that channel",
+		},
+	},
+	{
+		Desc:   "part all channels",
+		Hook:   CMD_JOIN,
+		Func:   Join,
+		Sender: "alice",
+		Args:   []string{"0"},
+		ToClient: []string{
+			"alice :alice PART #a",
+			"alice,bob :alice PART #b",
+		},
+		Unsorted: true,
+		ToServer: []string{
+			"1TA :alice JOIN 0",
+		},
+	},
+}
+
+func TestJoinPart(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	testUser(t, Config.SID, "alice")
+	testUser(t, Config.SID, "bob")
+
+	for _, test := range joinTests {
+		test.run(t)
+	}
+}
+
+var sjoinTests = []handlerTest{
+	{
+		Desc:   "burst a channel",
+		Hook:   CMD_SJOIN,
+		Func:   SJoin,
+		Sender: "1TA",
+		Prefix: "1TA",
+		Args:   []string{"1000", "#s", "+nt", "@carol dave"},
+		ToServer: []string{
+			"1TB :1TA SJOIN 1000 #s +nt :@carol dave",
+		},
+	},
+	{
+		Desc:   "remote join",
+		Hook:   CMD_JOIN,
+		Func:   SJoin,
+		Sender: "1TA",
+		Prefix: "erin",
+		Args:   []string{"1000", "#s", "+"},
+		ToClient: []string{
+			"alice :erin JOIN #s",
+		},
+		ToServer: []string{
+			"1TB :erin JOIN 1000 #s +",
+		},
+	},
+	{
+		Desc:   "remote part",
+		Hook:   CMD_PART,
+		Func:   Part,
+		Sender: "1TA",
+		Prefix: "carol",
+		Args:   []string{"#s", "later"},
+		ToClient: []string{
+			"alice :carol PART #s later",
+		},
+		ToServer: []string{
+			"1TB :carol PART #s later",
+		},
+	},
+	{
+		Desc:   "remote part all",
+		Hook:   CMD_JOIN,
+		Func:   SJoin,
+		Sender: "1TA",
+		Prefix: "dave",
+		Args:   []string{"0"},
+		ToClient: []string{
+			"alice :dave PART #s",
+		},
+		ToServer: []string{
+			"1TB :dave JOIN 0",
+		},
+	},
+}
+
+func TestSJoin(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	testLink(t, "1TB")
+	alice := testUser(t, Config.SID, "alice")
+	testUser(t, "1TA", "carol")
+	testUser(t, "1TA", "dave")
+	testUser(t, "1TA", "erin")
+
+	// alice joins #s after the burst, so that she sees the others join
+	// and part.
+	sjoinTests[0].run(t)
+	channel, _ := GetChannel("#s", false)
+	channel.Join(alice)
+	for _, test := range sjoinTests[1:] {
+		test.run(t)
+	}
+}
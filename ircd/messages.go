@@ -1,35 +1,67 @@
 package ircd
 
 import (
-	"bytes"
 	"log"
+	"sort"
 )
 
-// Construct a names message for the channel.
-func (c *Channel) NamesMessage(destIDs ...string) *Message {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+// Clients which have enabled multi-prefix are shown all of a channel
+// member's status prefixes in NAMES and WHO replies rather than only the
+// highest, and those which have enabled userhost-in-names are shown the full
+// nick!user@host of each member in NAMES replies.
+var (
+	capMultiPrefix     = RegisterCap("multi-prefix", "")
+	capUserhostInNames = RegisterCap("userhost-in-names", "")
+)
+
+// visiblePrefix returns the status prefixes of a channel member as shown to
+// the user.
+func visiblePrefix(uid, status string) string {
+	if len(status) > 1 && !GetUser(uid).HasCap(capMultiPrefix) {
+		return status[:1]
+	}
+	return status
+}
+
+// Construct the names messages for the channel as seen by the given user.
+// Invisible members are only listed if the user is on the channel, and the
+// names are split across as many messages as are needed to keep each line
+// within MaxLineLength.
+func (c *Channel) NamesMessages(uid string) []*Message {
+	nick, _, _, _, _ := GetUserInfo(uid)
+	member := c.OnChan(uid)
+	typ := c.Type()
+	userhost := GetUser(uid).HasCap(capUserhostInNames)
 
-	buf := bytes.NewBuffer(nil)
-	for id := range c.users {
+	names := []string{}
+	for _, id := range c.UserIDs() {
 		nick, _, _, _, ok := GetUserInfo(id)
 		if !ok {
 			log.Printf("Warning: Unknown id %q in %s", id, c.name)
 			continue
 		}
-		buf.WriteByte(' ')
-		buf.WriteString(nick)
+		if !member && GetUser(id).HasMode('i') {
+			continue
+		}
+		if userhost {
+			nick = GetUser(id).Hostmask()
+		}
+		names = append(names, visiblePrefix(uid, c.Status(id))+nick)
 	}
-	buf.ReadByte()
-	return &Message{
-		Command: RPL_NAMREPLY,
-		Args: []string{
-			// =public *private @secret
-			"*",
-			"@",
-			c.name,
-			buf.String(),
-		},
-		DestIDs: destIDs,
+	sort.Strings(names)
+
+	msgs := []*Message{}
+	for _, line := range joinNames(names, namesSpace(nick, typ, c.name)) {
+		msgs = append(msgs, &Message{
+			Command: RPL_NAMREPLY,
+			Args: []string{
+				"*",
+				typ,
+				c.name,
+				line,
+			},
+			DestIDs: []string{uid},
+		})
 	}
+	return msgs
 }
@@ -11,24 +11,73 @@ var (
 	}
 )
 
+// Clients which have enabled echo-message are sent a copy of each PRIVMSG,
+// NOTICE, and TAGMSG they send once it has been accepted.
+var capEchoMessage = RegisterCap("echo-message", "")
+
 func Privmsg(hook string, msg *Message, ircd *IRCd) {
 	quiet := hook == CMD_NOTICE
 	recipients, text := strings.Split(msg.Args[0], ","), msg.Args[1]
-	sender := msg.SenderID
-	if len(msg.Prefix) == 9 {
+	sender, fromClient := msg.SenderID, len(msg.SenderID) != 3
+	if !fromClient {
 		sender = msg.Prefix
 	}
-	local := []string{}
-	remote := []string{}
+	if fromClient {
+		GetUser(sender).SetActive()
+	}
+
+	// Clients may only send client-only tags
+	tags := msg.Tags
+	if fromClient {
+		tags = clientTags(msg.Tags)
+	}
+
+	// Clients which have enabled echo-message are sent their own messages
+	echo := func(target string, tags map[string]string) {
+		if !fromClient || !GetUser(sender).HasCap(capEchoMessage) {
+			return
+		}
+		ircd.ToClient <- &Message{
+			Tags:    tags,
+			Prefix:  sender,
+			Command: hook,
+			Args: []string{
+				target,
+				text,
+			},
+			DestIDs: []string{sender},
+		}
+	}
+
 	for _, name := range recipients {
+		if isMassTarget(name) {
+			if fromClient {
+				var err error = NewNumeric(ERR_NOPRIVILEGES)
+				if GetUser(sender).HasMode('o') {
+					err = checkMassTarget(name)
+				}
+				if num, ok := err.(*Numeric); ok {
+					if !quiet {
+						ircd.ToClient <- num.Message(sender)
+					}
+					continue
+				}
+			}
+			massMessage(hook, sender, name, text, msg.SenderID, ircd)
+			continue
+		}
 		if ValidChannel(name) {
 			channel, err := GetChannel(name, false)
+			if err == nil && fromClient {
+				err = channel.CanSend(sender)
+			}
 			if num, ok := err.(*Numeric); ok {
 				if !quiet {
 					ircd.ToClient <- num.Message(msg.SenderID)
 				}
 				continue
 			}
+			tags := stampTags(tags)
 			local := []string{}
 			remote := []string{}
 			for _, uid := range channel.UserIDs() {
@@ -44,6 +93,7 @@ func Privmsg(hook string, msg *Message, ircd *IRCd) {
 				for sid := range IterFor(remote, msg.SenderID) {
 					Debug.Printf("Forwarding PRIVMSG from %s to %s", msg.SenderID, sid)
 					ircd.ToServer <- &Message{
+						Tags:    serverTags(sid, tags),
 						Prefix:  sender,
 						Command: hook,
 						Args: []string{
@@ -56,6 +106,7 @@ func Privmsg(hook string, msg *Message, ircd *IRCd) {
 			}
 			if len(local) > 0 {
 				ircd.ToClient <- &Message{
+					Tags:    tags,
 					Prefix:  sender,
 					Command: hook,
 					Args: []string{
@@ -65,6 +116,8 @@ func Privmsg(hook string, msg *Message, ircd *IRCd) {
 					DestIDs: local,
 				}
 			}
+			recordHistory(historyTarget(channel.Name()), channel.Name(), hook, sender, text, tags)
+			echo(channel.Name(), tags)
 			continue
 		}
 
@@ -75,36 +128,41 @@ func Privmsg(hook string, msg *Message, ircd *IRCd) {
 			}
 			continue
 		}
+		if !quiet && fromClient {
+			sendAway(sender, id, ircd)
+		}
+		tags := stampTags(tags)
 		if id[:3] == Config.SID {
-			local = append(local, id)
+			ircd.ToClient <- &Message{
+				Tags:    tags,
+				Prefix:  sender,
+				Command: hook,
+				Args: []string{
+					"*",
+					text,
+				},
+				DestIDs: []string{id},
+			}
 		} else {
-			remote = append(remote, id)
-		}
-	}
-	if len(remote) > 0 {
-		for _, remoteid := range remote {
-			for sid := range IterFor([]string{remoteid}, "") {
+			for sid := range IterFor([]string{id}, "") {
 				ircd.ToServer <- &Message{
+					Tags:    serverTags(sid, tags),
 					Prefix:  sender,
 					Command: hook,
 					Args: []string{
-						remoteid,
+						id,
 						text,
 					},
 					DestIDs: []string{sid},
 				}
 			}
 		}
-	}
-	if len(local) > 0 {
-		ircd.ToClient <- &Message{
-			Prefix:  sender,
-			Command: hook,
-			Args: []string{
-				"*",
-				text,
-			},
-			DestIDs: local,
+
+		// Private conversations are recorded by the servers of both users
+		if fromClient || id[:3] == Config.SID {
+			nick := GetUser(id).Nick()
+			recordHistory(historyTarget(GetUser(sender).Nick(), nick), nick, hook, sender, text, tags, sender, id)
 		}
+		echo(id, tags)
 	}
 }
@@ -0,0 +1,46 @@
+package ircd
+
+import (
+	"testing"
+)
+
+var forgedPrefixTests = []handlerTest{
+	{
+		Desc:   "message to every user with a forged prefix",
+		Hook:   CMD_PRIVMSG,
+		Func:   Privmsg,
+		Sender: "mallory",
+		Prefix: "carol",
+		Args:   []string{"$*", "spam"},
+		ToClient: []string{
+			"mallory 481 * :Permission Denied- You're not an IRC operator",
+		},
+	},
+	{
+		Desc:   "message to a +n channel with a forged prefix",
+		Hook:   CMD_PRIVMSG,
+		Func:   Privmsg,
+		Sender: "mallory",
+		Prefix: "carol",
+		Args:   []string{"#forged", "spam"},
+		ToClient: []string{
+			"mallory 404 * #forged :Cannot send to channel",
+		},
+	},
+}
+
+func TestForgedPrefix(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	testUser(t, Config.SID, "mallory")
+	carol := testUser(t, "1TA", "carol")
+	alice := testUser(t, Config.SID, "alice")
+	channel, _ := GetChannel("#forged", true)
+	channel.Join(alice, carol)
+	changes, _ := ParseModeChange([]string{"+n"}, ChannelModes)
+	channel.ApplyModes(changes)
+
+	for _, test := range forgedPrefixTests {
+		test.run(t)
+	}
+}
@@ -57,7 +57,7 @@ func newModeSpec(ch rune, typ modeType, name string) *ModeSpec {
 	var prefix string
 	if typ == StatusMode {
 		if idx := strings.IndexRune(statusMode, ch); idx >= 0 {
-			prefix = statusPrefix
+			prefix = statusPrefix[idx : idx+1]
 		}
 	}
 	return &ModeSpec{
@@ -93,6 +93,7 @@ var (
 		'b': newModeSpec('b', ListMode, "banned"),
 		'e': newModeSpec('e', ListMode, "exempt from +b"),
 		'I': newModeSpec('I', ListMode, "exempt from +i"),
+		'i': newModeSpec('i', FlagMode, "invite only"),
 		'k': newModeSpec('k', KeyMode, "key required to join"),
 		'l': newModeSpec('l', LimitMode, "user count limit"),
 		'm': newModeSpec('m', FlagMode, "moderated"),
@@ -220,7 +221,7 @@ func (am ActiveModes) Apply(modes []Mode) (applied []Mode, errors []error) {
 				if !isset {
 					continue
 				}
-				if curr.Args[0] != m.Args[0] {
+				if typ == KeyMode && curr.Args[0] != m.Args[0] {
 					errors = append(errors, &UnsetMatchError{char})
 					continue
 				}
@@ -279,8 +280,9 @@ func (am ActiveModes) Match(ch rune, ref string) bool {
 	if m, isset = am[ch]; !isset {
 		return false
 	}
+	ref = ToLower(ref)
 	for _, arg := range m.Args {
-		if match, _ := filepath.Match(arg, ref); match {
+		if match, _ := filepath.Match(ToLower(arg), ref); match {
 			return true
 		}
 	}
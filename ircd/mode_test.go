@@ -179,3 +179,72 @@ func TestApplyModes(t *testing.T) {
 		}
 	}
 }
+
+var chanModeTests = []handlerTest{
+	{
+		Desc:   "member queries the modes",
+		Hook:   CMD_MODE,
+		Func:   ModeChange,
+		Sender: "alice",
+		Args:   []string{"#modes"},
+		ToClient: []string{
+			"alice 324 * #modes +kls secret 10",
+			"alice 329 * #modes 1000000000",
+		},
+	},
+	{
+		Desc:   "non-member queries the modes",
+		Hook:   CMD_MODE,
+		Func:   ModeChange,
+		Sender: "bob",
+		Args:   []string{"#modes"},
+		ToClient: []string{
+			"bob 324 * #modes +kls",
+			"bob 329 * #modes 1000000000",
+		},
+	},
+	{
+		Desc:   "member queries the bans",
+		Hook:   CMD_MODE,
+		Func:   ModeChange,
+		Sender: "alice",
+		Args:   []string{"#modes", "+b"},
+		ToClient: []string{
+			"alice 367 * #modes *!*@spam.example",
+			"alice 368 * #modes :End of channel ban list",
+		},
+	},
+	{
+		Desc:   "non-member queries the bans of a secret channel",
+		Hook:   CMD_MODE,
+		Func:   ModeChange,
+		Sender: "bob",
+		Args:   []string{"#modes", "+b"},
+		ToClient: []string{
+			"bob 442 * #modes :You're not on that channel",
+		},
+	},
+	{
+		Desc:   "ban list without a type",
+		Hook:   CMD_BMASK,
+		Func:   BMask,
+		Sender: "1TA",
+		Args:   []string{"1", "#modes", "", "*!*@more.example"},
+	},
+}
+
+func TestChanModeChange(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	alice := testUser(t, Config.SID, "alice")
+	testUser(t, Config.SID, "bob")
+	channel, _ := GetChannel("#modes", true)
+	channel.SetTS("1000000000")
+	channel.Join(alice)
+	changes, _ := ParseModeChange([]string{"+klsb", "secret", "10", "*!*@spam.example"}, ChannelModes)
+	channel.ApplyModes(changes)
+
+	for _, test := range chanModeTests {
+		test.run(t)
+	}
+}
@@ -0,0 +1,393 @@
+package ircd
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	modehooks = []*Hook{
+		Register(CMD_MODE, EMASK_USER, MinArgs(1), ModeChange),
+		Register(CMD_MODE, EMASK_SERVER, NArgs(2), SMode),
+		Register(CMD_TMODE, EMASK_SERVER, MinArgs(3), TMode),
+		Register(CMD_BMASK, EMASK_SERVER, NArgs(4), BMask),
+	}
+)
+
+// listNumerics[mode] = {list entry numeric, end of list numeric}
+var listNumerics = map[rune][2]string{
+	'b': {RPL_BANLIST, RPL_ENDOFBANLIST},
+	'e': {RPL_EXCEPTLIST, RPL_ENDOFEXCEPTLIST},
+	'I': {RPL_INVITELIST, RPL_ENDOFINVITELIST},
+}
+
+// Handle a MODE from a local client.
+//
+//	MODE <channel> [<modes> [<mode params>...]]
+//	MODE <nick> [<modes>]
+func ModeChange(hook string, msg *Message, ircd *IRCd) {
+	if ValidChannel(msg.Args[0]) {
+		chanModeChange(msg, ircd)
+		return
+	}
+	userModeChange(msg, ircd)
+}
+
+func userModeChange(msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	id, err := GetID(msg.Args[0])
+	if num, ok := err.(*Numeric); ok {
+		ircd.ToClient <- num.Message(uid)
+		return
+	}
+	if id != uid {
+		ircd.ToClient <- NewNumeric(ERR_USERSDONTMATCH).Message(uid)
+		return
+	}
+
+	u := GetUser(uid)
+	if len(msg.Args) == 1 {
+		ircd.ToClient <- NewNumeric(RPL_UMODEIS, u.Modes()).Message(uid)
+		return
+	}
+
+	changes, errs := ParseModeChange(msg.Args[1:], UserModes)
+	if len(errs) > 0 {
+		ircd.ToClient <- NewNumeric(ERR_UMODEUNKNOWNFLAG).Message(uid)
+	}
+
+	allowed := make([]Mode, 0, len(changes))
+	for _, m := range changes {
+		if m.Op == QueryMode || !userModeAllowed(m) {
+			continue
+		}
+		allowed = append(allowed, m)
+
+		// Losing operator status also loses administrator status
+		if m.Spec.Char() == 'o' {
+			allowed = append(allowed, Mode{
+				Spec: UserModes['a'],
+				Op:   UnsetMode,
+			})
+		}
+	}
+
+	applied, _ := u.ApplyModes(allowed)
+	announceUserModes(uid, applied, "", ircd)
+}
+
+// userModeAllowed returns whether a user may make the given change to their
+// own modes.  Operator status is only granted by OPER, and the modes which
+// reflect the user's connection or services status are only set by servers.
+func userModeAllowed(m Mode) bool {
+	switch m.Spec.Char() {
+	case 'o', 'a':
+		return m.Op == UnsetMode
+	case 'S', 'r', 'Z':
+		return false
+	}
+	return true
+}
+
+// announceUserModes notifies the user (if local) and all linked servers
+// (except the one behind skipLink) of changes to the user's modes.
+func announceUserModes(uid string, applied []Mode, skipLink string, ircd *IRCd) {
+	if len(applied) == 0 {
+		return
+	}
+	modes := ModeString(applied)
+	if uid[:3] == Config.SID {
+		ircd.ToClient <- &Message{
+			Prefix:  uid,
+			Command: CMD_MODE,
+			Args: []string{
+				uid,
+				modes,
+			},
+			DestIDs: []string{uid},
+		}
+	}
+	for sid := range ServerIter() {
+		if sid != skipLink {
+			ircd.ToServer <- &Message{
+				Prefix:  uid,
+				Command: CMD_MODE,
+				Args: []string{
+					uid,
+					modes,
+				},
+				DestIDs: []string{sid},
+			}
+		}
+	}
+}
+
+// Handle a user MODE from a linked server.
+//
+//	:<uid> MODE <uid> :<modes>
+func SMode(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.Args[0]
+	if _, _, _, _, ok := GetUserInfo(uid); !ok {
+		Warn.Printf("MODE for unknown user %s from %s", uid, msg.SenderID)
+		return
+	}
+
+	parsed, _ := ParseModeChange(msg.Args[1:], UserModes)
+	changes := make([]Mode, 0, len(parsed))
+	for _, m := range parsed {
+		if m.Op != QueryMode {
+			changes = append(changes, m)
+		}
+	}
+	applied, _ := GetUser(uid).ApplyModes(changes)
+	announceUserModes(uid, applied, msg.SenderID, ircd)
+}
+
+func chanModeChange(msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	channel, err := GetChannel(msg.Args[0], false)
+	if num, ok := err.(*Numeric); ok {
+		ircd.ToClient <- num.Message(uid)
+		return
+	}
+
+	if len(msg.Args) == 1 {
+		// Only members are shown the key and limit
+		modes := channel.Modes()
+		if !channel.OnChan(uid) {
+			modes = modes[:1]
+		}
+		ircd.ToClient <- &Message{
+			Command: RPL_CHANNELMODEIS,
+			Args:    append([]string{"*", channel.Name()}, modes...),
+			DestIDs: []string{uid},
+		}
+		ircd.ToClient <- NewNumeric(RPL_CREATIONTIME, channel.Name(), channel.TS()).Message(uid)
+		return
+	}
+
+	changes, errs := ParseModeChange(msg.Args[1:], ChannelModes)
+	for _, err := range errs {
+		switch err := err.(type) {
+		case *UnknownModeError:
+			ircd.ToClient <- NewNumeric(ERR_UNKNOWNMODE, string(err.Char)).Message(uid)
+		case *MissingArgumentError:
+			// A list mode without a mask (e.g. +b) is a query
+			if spec := ChannelModes[err.Char]; spec.Type() == ListMode {
+				changes = append(changes, Mode{Spec: spec, Op: QueryMode})
+			}
+		}
+	}
+
+	status := channel.Status(uid)
+	allowed := make([]Mode, 0, len(changes))
+	denied := false
+	for _, m := range changes {
+		if m.Op == QueryMode {
+			if m.Spec.Type() == ListMode {
+				sendModeList(uid, channel, m.Spec.Char(), ircd)
+			}
+			continue
+		}
+
+		if !chanModeAllowed(status, m.Spec) {
+			denied = true
+			continue
+		}
+
+		switch m.Spec.Type() {
+		case StatusMode:
+			id, err := GetID(m.Args[0])
+			if err == nil && !channel.OnChan(id) {
+				err = NewNumeric(ERR_USERNOTINCHANNEL, m.Args[0], channel.Name())
+			}
+			if num, ok := err.(*Numeric); ok {
+				ircd.ToClient <- num.Message(uid)
+				continue
+			}
+			m.Args = []string{id}
+		case KeyMode:
+			// Any key can be given to remove the current one
+			if m.Op == UnsetMode {
+				key := channel.ModeArgs(m.Spec.Char())
+				if len(key) == 0 {
+					continue
+				}
+				m.Args = key
+			}
+		case LimitMode:
+			if m.Op == SetMode {
+				if limit, err := strconv.Atoi(m.Args[0]); err != nil || limit <= 0 {
+					continue
+				}
+			}
+		}
+		allowed = append(allowed, m)
+	}
+	if denied {
+		ircd.ToClient <- NewNumeric(ERR_CHANOPRIVSNEEDED, channel.Name()).Message(uid)
+	}
+
+	applied, _ := channel.ApplyModes(allowed)
+	if len(applied) == 0 {
+		return
+	}
+	notifyModes(uid, channel, applied, ircd)
+
+	modes := strings.Fields(ModeString(applied))
+	for sid := range ServerIter() {
+		ircd.ToServer <- &Message{
+			Prefix:  uid,
+			Command: CMD_TMODE,
+			Args:    append([]string{channel.TS(), channel.Name()}, modes...),
+			DestIDs: []string{sid},
+		}
+	}
+}
+
+// chanModeAllowed returns whether a member with the given status prefixes may
+// change the mode.  Operators may change any mode and half-operators may
+// change any mode except operator and half-operator status.
+func chanModeAllowed(status string, spec *ModeSpec) bool {
+	switch {
+	case strings.Contains(status, "@"):
+		return true
+	case strings.Contains(status, "%"):
+		return spec.Char() != 'o' && spec.Char() != 'h'
+	}
+	return false
+}
+
+// sendModeList sends the entries of a list mode to the user, unless the
+// channel is secret or private and they are not on it.
+func sendModeList(uid string, channel *Channel, ch rune, ircd *IRCd) {
+	if !channel.Visible(uid) {
+		ircd.ToClient <- NewNumeric(ERR_NOTONCHANNEL, channel.Name()).Message(uid)
+		return
+	}
+	numerics := listNumerics[ch]
+	for _, mask := range channel.ModeArgs(ch) {
+		ircd.ToClient <- NewNumeric(numerics[0], channel.Name(), mask).Message(uid)
+	}
+	ircd.ToClient <- NewNumeric(numerics[1], channel.Name()).Message(uid)
+}
+
+// notifyModes notifies the local members of a channel of mode changes.
+func notifyModes(source string, channel *Channel, applied []Mode, ircd *IRCd) {
+	if len(applied) == 0 {
+		return
+	}
+	local := localIDs(channel.UserIDs())
+	if len(local) == 0 {
+		return
+	}
+	ircd.ToClient <- &Message{
+		Prefix:  sourceName(source),
+		Command: CMD_MODE,
+		Args:    append([]string{channel.Name()}, strings.Fields(ModeString(applied))...),
+		DestIDs: local,
+	}
+}
+
+// sourceName returns the prefix with which local clients should see a
+// message from the given source.  UIDs are left alone (they are expanded when
+// the message is sent) and SIDs are replaced by the server name.
+func sourceName(source string) string {
+	if len(source) != 3 {
+		return source
+	}
+	if source == Config.SID {
+		return Config.Name
+	}
+	if _, name, _, _, ok := GetServerInfo(source); ok {
+		return name
+	}
+	return source
+}
+
+// newerTS returns true if the TS is newer than the channel's TS.
+func newerTS(ts string, channel *Channel) bool {
+	theirs, _ := strconv.ParseInt(ts, 10, 64)
+	ours, _ := strconv.ParseInt(channel.TS(), 10, 64)
+	return theirs > ours
+}
+
+// Handle a TMODE from a linked server.
+//
+//	:<uid|sid> TMODE <ts> <channel> <modes> [<mode params>...]
+func TMode(hook string, msg *Message, ircd *IRCd) {
+	ts, name := msg.Args[0], msg.Args[1]
+
+	channel, err := GetChannel(name, false)
+	if err != nil {
+		Warn.Printf("TMODE for unknown channel %s from %s", name, msg.SenderID)
+		return
+	}
+	if newerTS(ts, channel) {
+		Debug.Printf("Ignoring TMODE for %s with newer TS %s", name, ts)
+		return
+	}
+
+	parsed, _ := ParseModeChange(msg.Args[2:], ChannelModes)
+	changes := make([]Mode, 0, len(parsed))
+	for _, m := range parsed {
+		if m.Op != QueryMode {
+			changes = append(changes, m)
+		}
+	}
+	applied, _ := channel.ApplyModes(changes)
+	notifyModes(msg.Prefix, channel, applied, ircd)
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			fmsg := msg.Dup()
+			fmsg.DestIDs = []string{sid}
+			ircd.ToServer <- fmsg
+		}
+	}
+}
+
+// Handle a BMASK from a linked server.
+//
+//	:<sid> BMASK <ts> <channel> <type> :<masks>
+func BMask(hook string, msg *Message, ircd *IRCd) {
+	ts, name, typ, masks := msg.Args[0], msg.Args[1], msg.Args[2], msg.Args[3]
+
+	channel, err := GetChannel(name, false)
+	if err != nil {
+		Warn.Printf("BMASK for unknown channel %s from %s", name, msg.SenderID)
+		return
+	}
+	if newerTS(ts, channel) {
+		Debug.Printf("Ignoring BMASK for %s with newer TS %s", name, ts)
+		return
+	}
+
+	var spec *ModeSpec
+	if len(typ) == 1 {
+		spec = ChannelModes[rune(typ[0])]
+	}
+	if spec == nil || spec.Type() != ListMode {
+		Warn.Printf("BMASK with unknown type %q from %s", typ, msg.SenderID)
+		return
+	}
+
+	changes := []Mode{}
+	for _, mask := range strings.Fields(masks) {
+		changes = append(changes, Mode{
+			Spec: spec,
+			Op:   SetMode,
+			Args: []string{mask},
+		})
+	}
+	applied, _ := channel.ApplyModes(changes)
+	notifyModes(msg.Prefix, channel, applied, ircd)
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			fmsg := msg.Dup()
+			fmsg.DestIDs = []string{sid}
+			ircd.ToServer <- fmsg
+		}
+	}
+}
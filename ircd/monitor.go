@@ -0,0 +1,232 @@
+package ircd
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	monitorhooks = []*Hook{
+		Register(CMD_MONITOR, EMASK_USER, OptArgs(1, 1), Monitor),
+	}
+)
+
+// The most nicks a user may monitor (advertised in RPL_ISUPPORT).
+const MaxMonitor = 100
+
+// The nicks monitored by each local user and, for each nick, the users who
+// monitor it.  Nicks are stored in lower case, with the case in which they
+// were given kept for the monitor list.
+var (
+	monMutex   = new(sync.RWMutex)
+	monitoring = make(map[string]map[string]string) // monitoring[uid][nick] = given nick
+	monitors   = make(map[string]map[string]bool)   // monitors[nick][uid] = true
+)
+
+// addMonitor adds the nicks to the user's monitor list.  It returns the
+// nicks which were added and those which were not because the list is full.
+func addMonitor(uid string, nicks []string) (added, full []string) {
+	monMutex.Lock()
+	defer monMutex.Unlock()
+
+	list := monitoring[uid]
+	if list == nil {
+		list = make(map[string]string)
+		monitoring[uid] = list
+	}
+	for _, nick := range nicks {
+		lower := ToLower(nick)
+		if _, ok := list[lower]; ok {
+			continue
+		}
+		if len(list) >= MaxMonitor {
+			full = append(full, nick)
+			continue
+		}
+		list[lower] = nick
+		if monitors[lower] == nil {
+			monitors[lower] = make(map[string]bool)
+		}
+		monitors[lower][uid] = true
+		added = append(added, nick)
+	}
+	return
+}
+
+// delMonitor removes the nicks from the user's monitor list.
+func delMonitor(uid string, nicks []string) {
+	monMutex.Lock()
+	defer monMutex.Unlock()
+	for _, nick := range nicks {
+		delMonitorLocked(uid, ToLower(nick))
+	}
+}
+
+// delMonitorLocked removes the lower case nick from the user's monitor list.
+// The caller must hold monMutex.
+func delMonitorLocked(uid, lower string) {
+	delete(monitoring[uid], lower)
+	if len(monitoring[uid]) == 0 {
+		delete(monitoring, uid)
+	}
+	delete(monitors[lower], uid)
+	if len(monitors[lower]) == 0 {
+		delete(monitors, lower)
+	}
+}
+
+// clearMonitor empties the user's monitor list.
+func clearMonitor(uid string) {
+	monMutex.Lock()
+	defer monMutex.Unlock()
+	for lower := range monitoring[uid] {
+		delMonitorLocked(uid, lower)
+	}
+}
+
+// monitorList returns the user's monitor list, sorted.
+func monitorList(uid string) []string {
+	monMutex.RLock()
+	defer monMutex.RUnlock()
+	nicks := make([]string, 0, len(monitoring[uid]))
+	for _, nick := range monitoring[uid] {
+		nicks = append(nicks, nick)
+	}
+	sort.Strings(nicks)
+	return nicks
+}
+
+// monitorsOf returns the users who monitor the nick.
+func monitorsOf(nick string) []string {
+	monMutex.RLock()
+	defer monMutex.RUnlock()
+	uids := make([]string, 0, len(monitors[ToLower(nick)]))
+	for uid := range monitors[ToLower(nick)] {
+		uids = append(uids, uid)
+	}
+	return uids
+}
+
+// joinTargets joins the targets with commas into lines no longer than max.
+func joinTargets(targets []string, max int) []string {
+	lines := []string{}
+	line := ""
+	for _, target := range targets {
+		if len(line) > 0 && len(line)+1+len(target) > max {
+			lines = append(lines, line)
+			line = ""
+		}
+		if len(line) > 0 {
+			line += ","
+		}
+		line += target
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// sendMonitorNumeric sends the targets to the local user in as many of the
+// numeric as are needed.
+func sendMonitorNumeric(uid, num string, targets []string, ircd *IRCd) {
+	// :<server> <num> <nick> :<targets>\r\n
+	space := MaxLineLength - (1 + len(Config.Name) + 1 + len(num) + 1 +
+		len(GetUser(uid).Nick()) + 2 + 2)
+	for _, line := range joinTargets(targets, space) {
+		ircd.ToClient <- NewNumeric(num, line).Message(uid)
+	}
+}
+
+// sendMonitorStatus tells the local user which of the nicks are online.
+func sendMonitorStatus(uid string, nicks []string, ircd *IRCd) {
+	online, offline := []string{}, []string{}
+	for _, nick := range nicks {
+		if id, err := GetID(nick); err == nil {
+			online = append(online, GetUser(id).Hostmask())
+		} else {
+			offline = append(offline, nick)
+		}
+	}
+	if len(online) > 0 {
+		sendMonitorNumeric(uid, RPL_MONONLINE, online, ircd)
+	}
+	if len(offline) > 0 {
+		sendMonitorNumeric(uid, RPL_MONOFFLINE, offline, ircd)
+	}
+}
+
+// monitorOnline tells the users who monitor the nicks of the given users
+// that they are online.
+func monitorOnline(uids []string, ircd *IRCd) {
+	notify := make(map[string][]string)
+	for _, uid := range uids {
+		u := GetUser(uid)
+		for _, watcher := range monitorsOf(u.Nick()) {
+			notify[watcher] = append(notify[watcher], u.Hostmask())
+		}
+	}
+	for watcher, masks := range notify {
+		sendMonitorNumeric(watcher, RPL_MONONLINE, masks, ircd)
+	}
+}
+
+// monitorOffline tells the users who monitor the nicks that they are
+// offline.
+func monitorOffline(nicks []string, ircd *IRCd) {
+	notify := make(map[string][]string)
+	for _, nick := range nicks {
+		for _, watcher := range monitorsOf(nick) {
+			notify[watcher] = append(notify[watcher], nick)
+		}
+	}
+	for watcher, nicks := range notify {
+		sendMonitorNumeric(watcher, RPL_MONOFFLINE, nicks, ircd)
+	}
+}
+
+// Handle a MONITOR from a local client.
+//
+//	MONITOR + <nick>{,<nick>}
+//	MONITOR - <nick>{,<nick>}
+//	MONITOR C
+//	MONITOR L
+//	MONITOR S
+func Monitor(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+
+	switch sub := strings.ToUpper(msg.Args[0]); sub {
+	case "+", "-":
+		if len(msg.Args) < 2 {
+			ircd.ToClient <- NewNumeric(ERR_NEEDMOREPARAMS, hook).Message(uid)
+			return
+		}
+		nicks := []string{}
+		for _, nick := range strings.Split(msg.Args[1], ",") {
+			if ValidNick(nick) {
+				nicks = append(nicks, nick)
+			}
+		}
+		if sub == "-" {
+			delMonitor(uid, nicks)
+			return
+		}
+		added, full := addMonitor(uid, nicks)
+		if len(full) > 0 {
+			ircd.ToClient <- NewNumeric(ERR_MONLISTFULL,
+				strconv.Itoa(MaxMonitor), strings.Join(full, ",")).Message(uid)
+		}
+		sendMonitorStatus(uid, added, ircd)
+	case "C":
+		clearMonitor(uid)
+	case "L":
+		if list := monitorList(uid); len(list) > 0 {
+			sendMonitorNumeric(uid, RPL_MONLIST, list, ircd)
+		}
+		ircd.ToClient <- NewNumeric(RPL_ENDOFMONLIST).Message(uid)
+	case "S":
+		sendMonitorStatus(uid, monitorList(uid), ircd)
+	}
+}
@@ -0,0 +1,54 @@
+package ircd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMonitorList(t *testing.T) {
+	uid := NextUserID()
+	defer clearMonitor(uid)
+
+	added, full := addMonitor(uid, []string{"Alice", "bob", "alice"})
+	if got, want := strings.Join(added, ","), "Alice,bob"; got != want {
+		t.Errorf("added = %q, want %q", got, want)
+	}
+	if len(full) > 0 {
+		t.Errorf("full = %q, want none", full)
+	}
+	if got, want := strings.Join(monitorsOf("ALICE"), ","), uid; got != want {
+		t.Errorf("monitorsOf = %q, want %q", got, want)
+	}
+
+	delMonitor(uid, []string{"BOB"})
+	if got, want := strings.Join(monitorList(uid), ","), "Alice"; got != want {
+		t.Errorf("monitorList = %q, want %q", got, want)
+	}
+	if got := monitorsOf("bob"); len(got) > 0 {
+		t.Errorf("monitorsOf(bob) = %q, want none", got)
+	}
+
+	nicks := []string{}
+	for i := 0; i < MaxMonitor; i++ {
+		nicks = append(nicks, "nick"+string(rune('a'+i%26))+string(rune('a'+i/26)))
+	}
+	added, full = addMonitor(uid, nicks)
+	if got, want := len(added), MaxMonitor-1; got != want {
+		t.Errorf("len(added) = %d, want %d", got, want)
+	}
+	if got, want := len(full), 1; got != want {
+		t.Errorf("len(full) = %d, want %d", got, want)
+	}
+
+	clearMonitor(uid)
+	if got := monitorsOf("alice"); len(got) > 0 {
+		t.Errorf("monitorsOf(alice) after clear = %q, want none", got)
+	}
+}
+
+func TestJoinTargets(t *testing.T) {
+	got := joinTargets([]string{"aa", "bb", "cc", "dd"}, 5)
+	if got, want := strings.Join(got, " "), "aa,bb cc,dd"; got != want {
+		t.Errorf("joinTargets = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,46 @@
+package ircd
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The cached contents of the message of the day file.
+var (
+	motdMutex = new(sync.Mutex)
+	motdFile  string
+	motdTime  time.Time
+	motdLines []string
+)
+
+// ReadMOTD returns the lines of the message of the day file.  The file is
+// reread whenever it (or the configured filename) changes, so the MOTD can be
+// updated without restarting the server.
+func ReadMOTD(filename string) ([]string, error) {
+	motdMutex.Lock()
+	defer motdMutex.Unlock()
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if filename == motdFile && info.ModTime().Equal(motdTime) {
+		return motdLines, nil
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(contents), "\r\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, "\r")
+	}
+
+	Info.Printf("Loaded MOTD from %s (%d lines)", filename, len(lines))
+	motdFile, motdTime, motdLines = filename, info.ModTime(), lines
+	return lines, nil
+}
@@ -0,0 +1,49 @@
+package ircd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadMOTD(t *testing.T) {
+	dir, err := ioutil.TempDir("", "motd")
+	if err != nil {
+		t.Fatalf("TempDir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "ircd.motd")
+
+	if _, err := ReadMOTD(filename); err == nil {
+		t.Errorf("ReadMOTD on missing file succeeded")
+	}
+
+	tests := []struct {
+		Contents string
+		Lines    []string
+	}{
+		{"Welcome\r\n\r\nto blight\r\n", []string{"Welcome", "", "to blight"}},
+		{"Changed\n", []string{"Changed"}},
+	}
+
+	for idx, test := range tests {
+		if err := ioutil.WriteFile(filename, []byte(test.Contents), 0644); err != nil {
+			t.Fatalf("#%d: WriteFile: %s", idx, err)
+		}
+		// Make sure the modification time changes
+		mtime := time.Now().Add(time.Duration(idx) * time.Second)
+		os.Chtimes(filename, mtime, mtime)
+
+		lines, err := ReadMOTD(filename)
+		if err != nil {
+			t.Errorf("#%d: ReadMOTD: %s", idx, err)
+			continue
+		}
+		if got, want := strings.Join(lines, "|"), strings.Join(test.Lines, "|"); got != want {
+			t.Errorf("#%d: ReadMOTD() = %q, want %q", idx, got, want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package ircd
+
+import (
+	"strconv"
+)
+
+var (
+	nickhooks = []*Hook{
+		Register(CMD_NICK, EMASK_USER, AnyArgs, Nick),
+		Register(CMD_NICK, EMASK_SERVER, NArgs(2), SNick),
+	}
+)
+
+// notifyNick notifies the user (if local) and every local user who shares a
+// channel with them of a nick change.  Each user is notified only once.
+func notifyNick(uid, oldmask, nick string, ircd *IRCd) {
+	peers := map[string]bool{}
+	if uid[:3] == Config.SID {
+		peers[uid] = true
+	}
+	for _, channel := range UserChannels(uid) {
+		for _, id := range localIDs(channel.UserIDs()) {
+			peers[id] = true
+		}
+	}
+	if len(peers) == 0 {
+		return
+	}
+
+	dest := make([]string, 0, len(peers))
+	for id := range peers {
+		dest = append(dest, id)
+	}
+	ircd.ToClient <- &Message{
+		Prefix:  oldmask,
+		Command: CMD_NICK,
+		Args: []string{
+			nick,
+		},
+		DestIDs: dest,
+	}
+}
+
+// Handle a NICK from a registered local client.
+//
+//	NICK <nick>
+func Nick(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	if len(msg.Args) == 0 {
+		ircd.ToClient <- NewNumeric(ERR_NONICKNAMEGIVEN).Message(uid)
+		return
+	}
+
+	u := GetUser(uid)
+	oldnick, oldmask := u.Nick(), u.Hostmask()
+	entry, _ := whowasEntry(uid)
+
+	nick := msg.Args[0]
+	if err := u.SetNick(nick); err != nil {
+		if num, ok := err.(*Numeric); ok {
+			ircd.ToClient <- num.Message(uid)
+		}
+		return
+	}
+	if nick == oldnick {
+		return
+	}
+	if ToLower(nick) != ToLower(oldnick) {
+		addWhowas(entry)
+		monitorOffline([]string{oldnick}, ircd)
+		monitorOnline([]string{uid}, ircd)
+	}
+
+	notifyNick(uid, oldmask, nick, ircd)
+
+	for sid := range ServerIter() {
+		ircd.ToServer <- &Message{
+			Prefix:  uid,
+			Command: CMD_NICK,
+			Args: []string{
+				nick,
+				u.TS(),
+			},
+			DestIDs: []string{sid},
+		}
+	}
+}
+
+// Handle a NICK from a linked server.
+//
+//	:<uid> NICK <nick> :<nick ts>
+func SNick(hook string, msg *Message, ircd *IRCd) {
+	uid, nick, ts := msg.Prefix, msg.Args[0], msg.Args[1]
+	oldnick, _, _, _, ok := GetUserInfo(uid)
+	if !ok {
+		Warn.Printf("NICK for unknown user %s from %s", uid, msg.SenderID)
+		return
+	}
+
+	u := GetUser(uid)
+	oldmask := u.Hostmask()
+	entry, _ := whowasEntry(uid)
+
+	err := u.SetRemoteNick(nick, ts)
+	if other, idErr := GetID(nick); err != nil && idErr == nil && other != uid {
+		if !nickCollision(uid, ts, other, ircd) {
+			return
+		}
+		err = u.SetRemoteNick(nick, ts)
+	}
+	if err != nil {
+		Warn.Printf("NICK %s for %s from %s: %s", nick, uid, msg.SenderID, err)
+		return
+	}
+	if nick == oldnick {
+		return
+	}
+	if ToLower(nick) != ToLower(oldnick) {
+		addWhowas(entry)
+		monitorOffline([]string{oldnick}, ircd)
+		monitorOnline([]string{uid}, ircd)
+	}
+
+	notifyNick(uid, oldmask, nick, ircd)
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			fmsg := msg.Dup()
+			fmsg.DestIDs = []string{sid}
+			ircd.ToServer <- fmsg
+		}
+	}
+}
+
+// nickCollision resolves a collision between a remote user changing their
+// nick (with the given nick TS) and the user who already has it as TS6 does:
+// if their user@hosts differ the older nick wins, if they are the same the
+// newer nick wins, and if the TSs are equal both lose.  The losers are killed
+// and their KILLs are sent to every server.  It returns whether the nick
+// change should be applied.
+func nickCollision(uid, ts, other string, ircd *IRCd) bool {
+	u, o := GetUser(uid), GetUser(other)
+	its, _ := strconv.ParseInt(ts, 10, 64)
+	ots, _ := strconv.ParseInt(o.TS(), 10, 64)
+	sameuser := u.User() == o.User() && u.Host() == o.Host()
+	Info.Printf("Nick collision between %s (%d) and %s (%d)", uid, its, other, ots)
+
+	losers := []string{}
+	switch {
+	case its == ots:
+		losers = append(losers, uid, other)
+	case sameuser == (its < ots):
+		losers = append(losers, uid)
+	default:
+		losers = append(losers, other)
+	}
+
+	applied := true
+	for _, victim := range losers {
+		for sid := range ServerIter() {
+			ircd.ToServer <- &Message{
+				Prefix:  Config.SID,
+				Command: CMD_KILL,
+				Args: []string{
+					victim,
+					Config.Name + " (Nick collision)",
+				},
+				DestIDs: []string{sid},
+			}
+		}
+		killUser(victim, Config.Name, "Nick collision", ircd)
+		releaseNick(victim)
+		if victim == uid {
+			applied = false
+		}
+	}
+	return applied
+}
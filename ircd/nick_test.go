@@ -0,0 +1,78 @@
+package ircd
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+var nickCollisionTests = []struct {
+	Desc   string
+	TS     string // of the nick change; the existing nick's TS is 2000
+	User   string // of the user changing nick; the existing user is carol
+	Killed string // "new" (the user changing nick), "old", or "both"
+}{
+	{"newer nick, different user", "3000", "dave", "new"},
+	{"older nick, different user", "1000", "dave", "old"},
+	{"newer nick, same user", "3000", "carol", "old"},
+	{"older nick, same user", "1000", "carol", "new"},
+	{"same TS", "2000", "dave", "both"},
+}
+
+func TestNickCollision(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	testLink(t, "1TB")
+
+	for _, test := range nickCollisionTests {
+		t.Run(test.Desc, func(t *testing.T) {
+			carol, dave := "1TB"+NextUserID()[3:], "1TA"+NextUserID()[3:]
+			Import(carol, "carol", "carol", "carol.example", "127.0.0.1", "1", "2000", "+", "Carol User")
+			Import(dave, "dave", test.User, test.User+".example", "127.0.0.1", "1", "1500", "+", "Dave User")
+			defer Delete(carol)
+			defer Delete(dave)
+
+			ircd := testIRCd()
+			SNick(CMD_NICK, &Message{
+				SenderID: "1TA",
+				Prefix:   dave,
+				Command:  CMD_NICK,
+				Args:     []string{"Carol", test.TS},
+			}, ircd)
+
+			killed, forwarded := []string{}, false
+			for len(ircd.ToServer) > 0 {
+				msg := <-ircd.ToServer
+				if msg.DestIDs[0] != "1TB" {
+					continue
+				}
+				switch msg.Command {
+				case CMD_KILL:
+					killed = append(killed, msg.Args[0])
+				case CMD_NICK:
+					forwarded = true
+				}
+			}
+			sort.Strings(killed)
+
+			want := map[string][]string{
+				"new":  {dave},
+				"old":  {carol},
+				"both": {carol, dave},
+			}[test.Killed]
+			sort.Strings(want)
+			if got, want := strings.Join(killed, ","), strings.Join(want, ","); got != want {
+				t.Errorf("killed %q, want %q", got, want)
+			}
+
+			if got, want := forwarded, test.Killed == "old"; got != want {
+				t.Errorf("NICK forwarded = %v, want %v", got, want)
+			}
+			if test.Killed == "old" {
+				if id, err := GetID("carol"); err != nil || id != dave {
+					t.Errorf("carol is %q (%v), want %q", id, err, dave)
+				}
+			}
+		})
+	}
+}
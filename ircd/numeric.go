@@ -22,15 +22,22 @@ func NewNumeric(num string, args ...string) *Numeric {
 	numargs := append([]string{"*"}) // reserve one for the nick
 
 	argcnt := 0
-	if len(pieces) > 1 {
+	switch {
+	case len(pieces) > 1:
 		argcnt = strings.Count(pieces[0], "<")
+	case strings.HasPrefix(text, "<"):
+		// Numerics like RPL_BANLIST are all arguments and have no text
+		argcnt = strings.Count(text, "<")
+		pieces = nil
 	}
 
 	if got, want := len(args), argcnt; got != want {
 		log.Printf("Warning: %d arguments to %s, want %d", got, name, want)
 	}
 	numargs = append(numargs, args...)
-	numargs = append(numargs, pieces[len(pieces)-1])
+	if len(pieces) > 0 {
+		numargs = append(numargs, pieces[len(pieces)-1])
+	}
 
 	return &Numeric{
 		num:  num,
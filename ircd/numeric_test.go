@@ -1,6 +1,7 @@
 package ircd
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -22,3 +23,33 @@ func TestNumeric(t *testing.T) {
 		}
 	}
 }
+
+var numericArgTests = []struct {
+	Numeric    string
+	Args       []string
+	ExpectArgs []string
+}{
+	{
+		Numeric:    ERR_NOSUCHCHANNEL,
+		Args:       []string{"#chan"},
+		ExpectArgs: []string{"*", "#chan", "No such channel"},
+	},
+	{
+		Numeric:    ERR_NOMOTD,
+		ExpectArgs: []string{"*", "MOTD File is missing"},
+	},
+	{
+		Numeric:    RPL_BANLIST,
+		Args:       []string{"#chan", "*!*@*"},
+		ExpectArgs: []string{"*", "#chan", "*!*@*"},
+	},
+}
+
+func TestNumericArgs(t *testing.T) {
+	for idx, test := range numericArgTests {
+		msg := NewNumeric(test.Numeric, test.Args...).Message()
+		if got, want := msg.Args, test.ExpectArgs; !reflect.DeepEqual(got, want) {
+			t.Errorf("#%d: %s args = %q, want %q", idx, test.Numeric, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ircd
+
+var (
+	operhooks = []*Hook{
+		Register(CMD_OPER, EMASK_USER, NArgs(2), OperUp),
+	}
+)
+
+// operModes returns the user mode changes granted by the operator's flags.
+// The "admin" flag implies "oper", since administrator status is lost along
+// with operator status.
+func operModes(oper *Oper) (changes []Mode) {
+	admin := oper.HasFlag("admin")
+	if admin || oper.HasFlag("oper") {
+		changes = append(changes, Mode{
+			Spec: UserModes['o'],
+			Op:   SetMode,
+		})
+	}
+	if admin {
+		changes = append(changes, Mode{
+			Spec: UserModes['a'],
+			Op:   SetMode,
+		})
+	}
+	return
+}
+
+// Handle an OPER from a local client.
+//
+//	OPER <name> <password>
+func OperUp(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	name, pass := msg.Args[0], msg.Args[1]
+	u := GetUser(uid)
+
+	var oper *Oper
+	for _, o := range Config.Operator {
+		if o.Name == name {
+			oper = o
+			break
+		}
+	}
+
+	var changes []Mode
+	if oper != nil && oper.MatchHost(u.Host(), u.IP()) {
+		changes = operModes(oper)
+	}
+	if len(changes) == 0 {
+		Info.Printf("[%s] Failed OPER %s: no matching operator", uid, name)
+		ircd.ToClient <- NewNumeric(ERR_NOOPERHOST).Message(uid)
+		return
+	}
+	if oper.Password == nil || !oper.Password.Check(pass) {
+		Info.Printf("[%s] Failed OPER %s: incorrect password", uid, name)
+		ircd.ToClient <- NewNumeric(ERR_PASSWDMISMATCH).Message(uid)
+		return
+	}
+
+	Info.Printf("[%s] OPER %s", uid, name)
+	applied, _ := u.ApplyModes(changes)
+	announceUserModes(uid, applied, "", ircd)
+	ircd.ToClient <- NewNumeric(RPL_YOUREOPER).Message(uid)
+}
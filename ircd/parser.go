@@ -6,6 +6,7 @@ import (
 )
 
 type Message struct {
+	Tags    map[string]string
 	Prefix  string
 	Command string
 	Args    []string
@@ -26,6 +27,12 @@ func NewMessage(pfx, cmd string, args []string) *Message {
 func (m *Message) Dup() *Message {
 	n := new(Message)
 	n.Prefix, n.Command, n.SenderID = m.Prefix, m.Command, m.SenderID
+	if m.Tags != nil {
+		n.Tags = make(map[string]string, len(m.Tags))
+		for name, value := range m.Tags {
+			n.Tags[name] = value
+		}
+	}
 	n.Args = make([]string, len(m.Args))
 	copy(n.Args, m.Args)
 	n.DestIDs = make([]string, len(m.DestIDs))
@@ -39,6 +46,14 @@ func ParseMessage(line []byte) *Message {
 		return nil
 	}
 	m := new(Message)
+	if line[0] == '@' {
+		split := bytes.SplitN(line, []byte{' '}, 2)
+		if len(split) <= 1 {
+			return nil
+		}
+		m.Tags = parseTags(string(split[0][1:]))
+		line = bytes.TrimLeft(split[1], " ")
+	}
 	if line[0] == ':' {
 		split := bytes.SplitN(line, []byte{' '}, 2)
 		if len(split) <= 1 {
@@ -47,7 +62,9 @@ func ParseMessage(line []byte) *Message {
 		m.Prefix = string(split[0][1:])
 		line = split[1]
 	}
-	split := bytes.SplitN(line, []byte{':'}, 2)
+	// The trailing argument follows the first " :"; other arguments may
+	// contain colons (as in timestamps)
+	split := bytes.SplitN(line, []byte(" :"), 2)
 	args := bytes.Split(bytes.TrimSpace(split[0]), []byte{' '})
 	m.Command = string(bytes.ToUpper(args[0]))
 	m.Args = make([]string, 0, len(args))
@@ -62,6 +79,11 @@ func ParseMessage(line []byte) *Message {
 
 func (m Message) Bytes() []byte {
 	buf := bytes.NewBuffer(make([]byte, 0, 512))
+	if len(m.Tags) > 0 {
+		buf.WriteByte('@')
+		buf.WriteString(formatTags(m.Tags))
+		buf.WriteByte(' ')
+	}
 	if len(m.Prefix) > 0 {
 		buf.WriteByte(':')
 		buf.WriteString(m.Prefix)
@@ -71,7 +93,7 @@ func (m Message) Bytes() []byte {
 	for i, arg := range m.Args {
 		buf.WriteByte(' ')
 		if i == len(m.Args)-1 {
-			if strings.IndexAny(arg, " :") >= 0 {
+			if len(arg) == 0 || strings.IndexAny(arg, " :") >= 0 {
 				buf.WriteByte(':')
 			}
 		}
@@ -13,6 +13,7 @@ var parse_message_tests = []struct {
 		"server.kevlar.net", "NOTICE", []string{"user", "*** This is a test"}},
 	{":A B C", "A", "B", []string{"C"}},
 	{"B C", "", "B", []string{"C"}},
+	{"B t=00:00 :a:b", "", "B", []string{"t=00:00", "a:b"}},
 }
 
 func TestParseMesage(t *testing.T) {
@@ -46,6 +47,7 @@ var build_message_tests = []struct {
 	{":A B C", "A", "B", []string{"C"}},
 	{"B C", "", "B", []string{"C"}},
 	{":A B C D", "A", "B", []string{"C", "D"}},
+	{":A B C :", "A", "B", []string{"C", ""}},
 }
 
 func TestBuildMessage(t *testing.T) {
@@ -86,32 +86,8 @@ func ConnReg(hook string, msg *Message, ircd *IRCd) {
 			}
 		}
 
-		nickname, username, realname, _ := u.Info()
-		if nickname != "*" && username != "" {
-			// Notify servers
-			for sid := range ServerIter() {
-				ircd.ToServer <- &Message{
-					Prefix:  Config.SID,
-					Command: CMD_UID,
-					Args: []string{
-						nickname,
-						"1",
-						u.TS(),
-						"+i",
-						username,
-						"some.host",
-						"127.0.0.1",
-						u.ID(),
-						realname,
-					},
-					DestIDs: []string{sid},
-				}
-			}
-
-			// Process signon
-			sendSignon(u, ircd)
-			return
-		}
+		registerUser(u, ircd)
+		return
 	}
 
 	if s != nil {
@@ -156,9 +132,51 @@ func ConnReg(hook string, msg *Message, ircd *IRCd) {
 	}
 }
 
+// registerUser completes the registration of a local user once their nick and
+// username are set and capability negotiation (if any) has ended.
+func registerUser(u *User, ircd *IRCd) {
+	nickname, username, realname, _ := u.Info()
+	if nickname == "*" || username == "" || u.Negotiating() {
+		return
+	}
+	if err := u.SetType(RegisteredAsUser); err != nil {
+		// Registration was completed by another message
+		return
+	}
+
+	changes, _ := ParseModeChange([]string{DefaultUserModes}, UserModes)
+	u.ApplyModes(changes)
+
+	// Notify servers
+	for sid := range ServerIter() {
+		ircd.ToServer <- &Message{
+			Prefix:  Config.SID,
+			Command: CMD_UID,
+			Args: []string{
+				nickname,
+				"1",
+				u.TS(),
+				u.Modes(),
+				username,
+				u.VisibleHost(),
+				u.IP(),
+				u.ID(),
+				realname,
+			},
+			DestIDs: []string{sid},
+		}
+	}
+	if account := u.Account(); len(account) > 0 {
+		sendEncap(u.ID(), "*", CMD_LOGIN, []string{account}, ircd)
+	}
+
+	// Process signon
+	sendSignon(u, ircd)
+	monitorOnline([]string{u.ID()}, ircd)
+}
+
 func sendSignon(u *User, ircd *IRCd) {
 	Info.Printf("[%s] ** Registered\n", u.ID())
-	u.SetType(RegisteredAsUser)
 
 	destIDs := []string{u.ID()}
 	// RPL_WELCOME
@@ -173,30 +191,21 @@ func sendSignon(u *User, ircd *IRCd) {
 	msg.DestIDs = destIDs
 	ircd.ToClient <- msg
 
-	// RPL_CREATED
-	// RPL_MYINFO
-	// RPL_ISUPPORT
+	// RPL_CREATED, RPL_MYINFO, RPL_ISUPPORT
+	sendWelcome(u.ID(), ircd)
 
-	// RPL_LUSERCLIENT
-	// RPL_LUSEROP
-	// RPL_LUSERUNKNOWN
-	// RPL_LUSERCHANNELS
-	// RPL_LUSERME
+	// RPL_LUSER*, RPL_LOCALUSERS, RPL_GLOBALUSERS
+	sendLusers(u.ID(), ircd)
 
-	// RPL_LOCALUSERS
-	// RPL_GLOBALUSERS
-
-	// RPL_NOMOTD
-	msg = NewNumeric(ERR_NOMOTD).Message()
-	msg.DestIDs = destIDs
-	ircd.ToClient <- msg
+	// RPL_MOTD* or ERR_NOMOTD
+	sendMotd(u.ID(), ircd)
 
 	msg = &Message{
 		Command: CMD_MODE,
 		Prefix:  "*",
 		Args: []string{
 			"*",
-			"+i",
+			u.Modes(),
 		},
 		DestIDs: destIDs,
 	}
@@ -227,7 +236,7 @@ func sendServerSignon(s *Server, ircd *IRCd) {
 		Command: CMD_CAPAB,
 		Args: []string{
 			//"QS EX CHW IE KLN KNOCK TB UNKLN CLUSTER ENCAP SERVICES RSFNC SAVE EUID EOPMOD BAN MLOCK",
-			"QS ENCAP", // TODO
+			"QS ENCAP TB " + capabMessageTags, // TODO
 		},
 		DestIDs: destIDs,
 	}
@@ -267,49 +276,107 @@ func Burst(serv *Server, ircd *IRCd) {
 				"1",
 				u.TS(),
 				// umodes
-				"+i",
+				u.Modes(),
 				username,
 				// visible hostname
-				"some.host",
+				u.VisibleHost(),
 				// IP addr
-				"127.0.0.1",
+				u.IP(),
 				uid,
 				name,
 			},
 			DestIDs: destIDs,
 		}
 		ircd.ToServer <- msg
+
+		if away := u.Away(); len(away) > 0 {
+			ircd.ToServer <- &Message{
+				Prefix:  uid,
+				Command: CMD_AWAY,
+				Args: []string{
+					away,
+				},
+				DestIDs: destIDs,
+			}
+		}
+
+		if account := u.Account(); len(account) > 0 {
+			ircd.ToServer <- &Message{
+				Prefix:  uid,
+				Command: CMD_ENCAP,
+				Args: []string{
+					"*",
+					CMD_LOGIN,
+					account,
+				},
+				DestIDs: destIDs,
+			}
+		}
 	}
-	// Optional: ENCAP REALHOST, ENCAP LOGIN, AWAY
+	// Optional: ENCAP REALHOST
 	// SJOIN
 	for channame := range ChannelIter() {
-		chanobj, _ := GetChannel(channame, false)
+		chanobj, err := GetChannel(channame, false)
+		if err != nil {
+			continue
+		}
+		args := []string{chanobj.TS(), channame}
+		args = append(args, chanobj.Modes()...)
+		args = append(args, strings.Join(chanobj.UserIDsWithPrefix(), " "))
 		msg = &Message{
 			Prefix:  sid,
 			Command: CMD_SJOIN,
-			Args: []string{
-				chanobj.TS(),
-				channame,
-				// modes, params...
-				"+", // "+nt",
-				strings.Join(chanobj.UserIDsWithPrefix(), " "),
-			},
+			Args:    args,
 			DestIDs: destIDs,
 		}
 		ircd.ToServer <- msg
+
+		// BMASK
+		for _, ch := range "beI" {
+			masks := chanobj.ModeArgs(ch)
+			if len(masks) == 0 {
+				continue
+			}
+			msg = &Message{
+				Prefix:  sid,
+				Command: CMD_BMASK,
+				Args: []string{
+					chanobj.TS(),
+					channame,
+					string(ch),
+					strings.Join(masks, " "),
+				},
+				DestIDs: destIDs,
+			}
+			ircd.ToServer <- msg
+		}
+
+		// TB
+		if topic, setter, ts := chanobj.Topic(); len(topic) > 0 {
+			msg = &Message{
+				Prefix:  sid,
+				Command: CMD_TB,
+				Args: []string{
+					channame,
+					ts,
+					setter,
+					topic,
+				},
+				DestIDs: destIDs,
+			}
+			ircd.ToServer <- msg
+		}
 	}
-	// Optional: BMAST
-	// Optional: TB
 }
 
 func Uid(hook string, msg *Message, ircd *IRCd) {
 	nickname, hopcount, nickTS := msg.Args[0], msg.Args[1], msg.Args[2]
 	umode, username, hostname := msg.Args[3], msg.Args[4], msg.Args[5]
 	ip, uid, name := msg.Args[6], msg.Args[7], msg.Args[8]
-	_ = umode
-
-	err := Import(uid, nickname, username, hostname, ip, hopcount, nickTS, name)
-	if err != nil {
+	err := Import(uid, nickname, username, hostname, ip, hopcount, nickTS, umode, name)
+	if err == nil {
+		monitorOnline([]string{uid}, ircd)
+	} else {
 		// TODO: TS check - Kill remote or local? For now, we kill remote.
 		ircd.ToServer <- &Message{
 			Prefix:  Config.SID,
@@ -382,13 +449,23 @@ func Quit(hook string, msg *Message, ircd *IRCd) {
 		}
 	}
 
-	members := PartAll(quitter)
+	removeUser(quitter, "Quit: "+reason, "Closing Link ("+reason+")", ircd)
+}
+
+// removeUser removes a user who is quitting or has been killed: the local
+// users who share a channel with them are sent a QUIT with the given message
+// and, if the user is local, they are sent an ERROR with the given reason and
+// disconnected.
+func removeUser(uid, quit, reason string, ircd *IRCd) {
+	nick := GetUser(uid).Nick()
+	AddWhowas(uid)
+	members := PartAll(uid)
 	Debug.Printf("QUIT recipients: %#v", members)
 	peers := make(map[string]bool)
 	for _, users := range members {
-		for _, uid := range users {
-			if uid[:3] == Config.SID && uid != quitter {
-				peers[uid] = true
+		for _, id := range users {
+			if id[:3] == Config.SID && id != uid {
+				peers[id] = true
 			}
 		}
 	}
@@ -398,23 +475,24 @@ func Quit(hook string, msg *Message, ircd *IRCd) {
 			notify = append(notify, peer)
 		}
 		ircd.ToClient <- &Message{
-			Prefix:  quitter,
+			Prefix:  uid,
 			Command: CMD_QUIT,
 			Args: []string{
-				"Quit: " + reason,
+				quit,
 			},
 			DestIDs: notify,
 		}
 	}
+	monitorOffline([]string{nick}, ircd)
 
 	// Will be dropped if it's a remote client
 	error := &Message{
 		Command: CMD_ERROR,
 		Args: []string{
-			"Closing Link (" + reason + ")",
+			reason,
 		},
 		DestIDs: []string{
-			quitter,
+			uid,
 		},
 	}
 	ircd.ToClient <- error
@@ -465,6 +543,13 @@ func SQuit(hook string, msg *Message, ircd *IRCd) {
 			}
 		}
 	}
+	nicks := make([]string, 0, len(peers))
+	for _, uid := range peers {
+		nick, _, _, _, _ := GetUserInfo(uid)
+		nicks = append(nicks, nick)
+	}
+	monitorOffline(nicks, ircd)
+
 	// Delete all of the peers
 	if len(peers) > 0 {
 		ircd.ToClient <- &Message{
@@ -0,0 +1,79 @@
+package ircd
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// The maximum length of a hostname shown to other users.
+const MaxHostLength = 63
+
+var (
+	// The resolver used to look up the hostnames of connecting clients.
+	// Tests may replace it with a fake.
+	DNS Resolver = netResolver{}
+
+	// How long to wait for a hostname lookup before using the IP address.
+	DNSTimeout = 5 * time.Second
+)
+
+// A Resolver performs reverse and forward DNS lookups.
+type Resolver interface {
+	// LookupAddr returns the names which map to the given address.
+	LookupAddr(addr string) (names []string, err error)
+
+	// LookupHost returns the addresses of the given host.
+	LookupHost(host string) (addrs []string, err error)
+}
+
+// netResolver is a Resolver which uses the system resolver.
+type netResolver struct{}
+
+func (netResolver) LookupAddr(addr string) ([]string, error) {
+	return net.LookupAddr(addr)
+}
+
+func (netResolver) LookupHost(host string) ([]string, error) {
+	return net.LookupHost(host)
+}
+
+// ReverseLookup performs a forward-confirmed reverse DNS lookup of the IP
+// address.  The first name to which the address resolves and which resolves
+// back to the address is returned.  If there is no such name or the lookup
+// takes longer than the timeout, ok is false.
+func ReverseLookup(r Resolver, ip string, timeout time.Duration) (host string, ok bool) {
+	found := make(chan string, 1)
+	go func() {
+		defer close(found)
+		names, err := r.LookupAddr(ip)
+		if err != nil {
+			Debug.Printf("Reverse lookup of %s: %s", ip, err)
+			return
+		}
+		for _, name := range names {
+			name = strings.TrimSuffix(name, ".")
+			if len(name) > MaxHostLength || !ValidServerName(name) {
+				continue
+			}
+			addrs, err := r.LookupHost(name)
+			if err != nil {
+				Debug.Printf("Forward lookup of %s: %s", name, err)
+				continue
+			}
+			// The address may be written differently (e.g. 0::1 for ::1)
+			for _, addr := range addrs {
+				if net.ParseIP(addr).Equal(net.ParseIP(ip)) {
+					found <- name
+					return
+				}
+			}
+		}
+	}()
+
+	select {
+	case host, ok = <-found:
+	case <-time.After(timeout):
+	}
+	return
+}
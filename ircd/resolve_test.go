@@ -0,0 +1,77 @@
+package ircd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeResolver maps addresses to names and names to addresses.  Lookups of
+// addresses in hang never return.
+type fakeResolver struct {
+	names map[string][]string
+	addrs map[string][]string
+	hang  map[string]bool
+}
+
+func (r *fakeResolver) LookupAddr(addr string) ([]string, error) {
+	if r.hang[addr] {
+		select {}
+	}
+	if names, ok := r.names[addr]; ok {
+		return names, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (r *fakeResolver) LookupHost(host string) ([]string, error) {
+	if addrs, ok := r.addrs[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestReverseLookup(t *testing.T) {
+	r := &fakeResolver{
+		names: map[string][]string{
+			"10.0.0.1": {"host.example.com."},
+			"10.0.0.2": {"spoofed.example.com."},
+			"10.0.0.3": {"bad_host!", "second.example.com"},
+			"10.0.0.4": {"nowhere.example.com"},
+			"0::1":     {"six.example.com"},
+		},
+		addrs: map[string][]string{
+			"host.example.com":    {"10.0.0.1"},
+			"spoofed.example.com": {"10.0.0.1"},
+			"second.example.com":  {"10.0.0.5", "10.0.0.3"},
+			"six.example.com":     {"::1"},
+		},
+		hang: map[string]bool{
+			"10.0.0.9": true,
+		},
+	}
+
+	tests := []struct {
+		IP   string
+		Host string
+		OK   bool
+	}{
+		{"10.0.0.1", "host.example.com", true},
+		{"10.0.0.2", "", false},
+		{"10.0.0.3", "second.example.com", true},
+		{"10.0.0.4", "", false},
+		{"10.0.0.8", "", false},
+		{"10.0.0.9", "", false},
+		{"0::1", "six.example.com", true},
+	}
+
+	for idx, test := range tests {
+		host, ok := ReverseLookup(r, test.IP, 10*time.Millisecond)
+		if got, want := host, test.Host; got != want {
+			t.Errorf("#%d: ReverseLookup(%q) host = %q, want %q", idx, test.IP, got, want)
+		}
+		if got, want := ok, test.OK; got != want {
+			t.Errorf("#%d: ReverseLookup(%q) ok = %v, want %v", idx, test.IP, got, want)
+		}
+	}
+}
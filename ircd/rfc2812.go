@@ -1,12 +1,12 @@
 package ircd
 
-// Automatically generated from doc/IRC-CustomNumerics.txt doc/IRC-RFC2812.txt
+// Automatically generated from doc/IRC-RFC2812.txt doc/IRC-CustomNumerics.txt
 const (
 	RPL_WELCOME           = "001"
 	RPL_YOURHOST          = "002"
 	RPL_CREATED           = "003"
 	RPL_MYINFO            = "004"
-	RPL_BOUNCE            = "005"
+	RPL_ISUPPORT          = "005"
 	RPL_TRACELINK         = "200"
 	RPL_TRACECONNECTING   = "201"
 	RPL_TRACEHANDSHAKE    = "202"
@@ -25,7 +25,6 @@ const (
 	RPL_SERVLISTEND       = "235"
 	RPL_STATSUPTIME       = "242"
 	RPL_STATSOLINE        = "243"
-	RPL_ISUPPORT          = "250"
 	RPL_LUSERCLIENT       = "251"
 	RPL_LUSEROP           = "252"
 	RPL_LUSERUNKNOWN      = "253"
@@ -36,6 +35,9 @@ const (
 	RPL_TRACELOG          = "261"
 	RPL_TRACEEND          = "262"
 	RPL_TRYAGAIN          = "263"
+	RPL_LOCALUSERS        = "265"
+	RPL_GLOBALUSERS       = "266"
+	RPL_WHOISCERTFP       = "276"
 	RPL_AWAY              = "301"
 	RPL_USERHOST          = "302"
 	RPL_ISON              = "303"
@@ -49,12 +51,16 @@ const (
 	RPL_WHOISIDLE         = "317"
 	RPL_ENDOFWHOIS        = "318"
 	RPL_WHOISCHANNELS     = "319"
+	RPL_LISTSTART         = "321"
 	RPL_LIST              = "322"
 	RPL_LISTEND           = "323"
 	RPL_CHANNELMODEIS     = "324"
 	RPL_UNIQOPIS          = "325"
+	RPL_CREATIONTIME      = "329"
+	RPL_WHOISACCOUNT      = "330"
 	RPL_NOTOPIC           = "331"
 	RPL_TOPIC             = "332"
+	RPL_TOPICWHOTIME      = "333"
 	RPL_INVITING          = "341"
 	RPL_SUMMONING         = "342"
 	RPL_INVITELIST        = "346"
@@ -92,6 +98,7 @@ const (
 	ERR_TOOMANYTARGETS    = "407"
 	ERR_NOSUCHSERVICE     = "408"
 	ERR_NOORIGIN          = "409"
+	ERR_INVALIDCAPCMD     = "410"
 	ERR_NORECIPIENT       = "411"
 	ERR_NOTEXTTOSEND      = "412"
 	ERR_NOTOPLEVEL        = "413"
@@ -125,7 +132,7 @@ const (
 	ERR_BANNEDFROMCHAN    = "474"
 	ERR_BADCHANNELKEY     = "475"
 	ERR_BADCHANMASK       = "476"
-	ERR_NOCHANMODES       = "477"
+	ERR_NEEDREGGEDNICK    = "477"
 	ERR_BANLISTFULL       = "478"
 	ERR_NOPRIVILEGES      = "481"
 	ERR_CHANOPRIVSNEEDED  = "482"
@@ -135,10 +142,24 @@ const (
 	ERR_NOOPERHOST        = "491"
 	ERR_UMODEUNKNOWNFLAG  = "501"
 	ERR_USERSDONTMATCH    = "502"
+	RPL_WHOISSECURE       = "671"
+	RPL_MONONLINE         = "730"
+	RPL_MONOFFLINE        = "731"
+	RPL_MONLIST           = "732"
+	RPL_ENDOFMONLIST      = "733"
+	ERR_MONLISTFULL       = "734"
+	RPL_LOGGEDIN          = "900"
+	RPL_LOGGEDOUT         = "901"
+	RPL_SASLSUCCESS       = "903"
+	ERR_SASLFAIL          = "904"
+	ERR_SASLTOOLONG       = "905"
+	ERR_SASLABORTED       = "906"
+	ERR_SASLALREADY       = "907"
+	RPL_SASLMECHS         = "908"
 	RPL_CUSTOM            = "999"
 )
 
-// Automatically generated from doc/IRC-CustomNumerics.txt doc/IRC-RFC2812.txt
+// Automatically generated from doc/IRC-RFC2812.txt doc/IRC-CustomNumerics.txt
 var NumericName = map[string]string{
 	ERR_ALREADYREGISTRED:  "ERR_ALREADYREGISTRED",
 	ERR_BADCHANMASK:       "ERR_BADCHANMASK",
@@ -152,13 +173,15 @@ var NumericName = map[string]string{
 	ERR_CHANOPRIVSNEEDED:  "ERR_CHANOPRIVSNEEDED",
 	ERR_ERRONEUSNICKNAME:  "ERR_ERRONEUSNICKNAME",
 	ERR_FILEERROR:         "ERR_FILEERROR",
+	ERR_INVALIDCAPCMD:     "ERR_INVALIDCAPCMD",
 	ERR_INVITEONLYCHAN:    "ERR_INVITEONLYCHAN",
 	ERR_KEYSET:            "ERR_KEYSET",
+	ERR_MONLISTFULL:       "ERR_MONLISTFULL",
 	ERR_NEEDMOREPARAMS:    "ERR_NEEDMOREPARAMS",
+	ERR_NEEDREGGEDNICK:    "ERR_NEEDREGGEDNICK",
 	ERR_NICKCOLLISION:     "ERR_NICKCOLLISION",
 	ERR_NICKNAMEINUSE:     "ERR_NICKNAMEINUSE",
 	ERR_NOADMININFO:       "ERR_NOADMININFO",
-	ERR_NOCHANMODES:       "ERR_NOCHANMODES",
 	ERR_NOLOGIN:           "ERR_NOLOGIN",
 	ERR_NOMOTD:            "ERR_NOMOTD",
 	ERR_NONICKNAMEGIVEN:   "ERR_NONICKNAMEGIVEN",
@@ -177,6 +200,10 @@ var NumericName = map[string]string{
 	ERR_NOTREGISTERED:     "ERR_NOTREGISTERED",
 	ERR_PASSWDMISMATCH:    "ERR_PASSWDMISMATCH",
 	ERR_RESTRICTED:        "ERR_RESTRICTED",
+	ERR_SASLABORTED:       "ERR_SASLABORTED",
+	ERR_SASLALREADY:       "ERR_SASLALREADY",
+	ERR_SASLFAIL:          "ERR_SASLFAIL",
+	ERR_SASLTOOLONG:       "ERR_SASLTOOLONG",
 	ERR_SUMMONDISABLED:    "ERR_SUMMONDISABLED",
 	ERR_TOOMANYCHANNELS:   "ERR_TOOMANYCHANNELS",
 	ERR_TOOMANYTARGETS:    "ERR_TOOMANYTARGETS",
@@ -196,15 +223,16 @@ var NumericName = map[string]string{
 	RPL_ADMINME:           "RPL_ADMINME",
 	RPL_AWAY:              "RPL_AWAY",
 	RPL_BANLIST:           "RPL_BANLIST",
-	RPL_BOUNCE:            "RPL_BOUNCE",
 	RPL_CHANNELMODEIS:     "RPL_CHANNELMODEIS",
 	RPL_CREATED:           "RPL_CREATED",
+	RPL_CREATIONTIME:      "RPL_CREATIONTIME",
 	RPL_CUSTOM:            "RPL_CUSTOM",
 	RPL_ENDOFBANLIST:      "RPL_ENDOFBANLIST",
 	RPL_ENDOFEXCEPTLIST:   "RPL_ENDOFEXCEPTLIST",
 	RPL_ENDOFINFO:         "RPL_ENDOFINFO",
 	RPL_ENDOFINVITELIST:   "RPL_ENDOFINVITELIST",
 	RPL_ENDOFLINKS:        "RPL_ENDOFLINKS",
+	RPL_ENDOFMONLIST:      "RPL_ENDOFMONLIST",
 	RPL_ENDOFMOTD:         "RPL_ENDOFMOTD",
 	RPL_ENDOFNAMES:        "RPL_ENDOFNAMES",
 	RPL_ENDOFSTATS:        "RPL_ENDOFSTATS",
@@ -213,6 +241,7 @@ var NumericName = map[string]string{
 	RPL_ENDOFWHOIS:        "RPL_ENDOFWHOIS",
 	RPL_ENDOFWHOWAS:       "RPL_ENDOFWHOWAS",
 	RPL_EXCEPTLIST:        "RPL_EXCEPTLIST",
+	RPL_GLOBALUSERS:       "RPL_GLOBALUSERS",
 	RPL_INFO:              "RPL_INFO",
 	RPL_INVITELIST:        "RPL_INVITELIST",
 	RPL_INVITING:          "RPL_INVITING",
@@ -221,11 +250,18 @@ var NumericName = map[string]string{
 	RPL_LINKS:             "RPL_LINKS",
 	RPL_LIST:              "RPL_LIST",
 	RPL_LISTEND:           "RPL_LISTEND",
+	RPL_LISTSTART:         "RPL_LISTSTART",
+	RPL_LOCALUSERS:        "RPL_LOCALUSERS",
+	RPL_LOGGEDIN:          "RPL_LOGGEDIN",
+	RPL_LOGGEDOUT:         "RPL_LOGGEDOUT",
 	RPL_LUSERCHANNELS:     "RPL_LUSERCHANNELS",
 	RPL_LUSERCLIENT:       "RPL_LUSERCLIENT",
 	RPL_LUSERME:           "RPL_LUSERME",
 	RPL_LUSEROP:           "RPL_LUSEROP",
 	RPL_LUSERUNKNOWN:      "RPL_LUSERUNKNOWN",
+	RPL_MONLIST:           "RPL_MONLIST",
+	RPL_MONOFFLINE:        "RPL_MONOFFLINE",
+	RPL_MONONLINE:         "RPL_MONONLINE",
 	RPL_MOTD:              "RPL_MOTD",
 	RPL_MOTDSTART:         "RPL_MOTDSTART",
 	RPL_MYINFO:            "RPL_MYINFO",
@@ -234,6 +270,8 @@ var NumericName = map[string]string{
 	RPL_NOUSERS:           "RPL_NOUSERS",
 	RPL_NOWAWAY:           "RPL_NOWAWAY",
 	RPL_REHASHING:         "RPL_REHASHING",
+	RPL_SASLMECHS:         "RPL_SASLMECHS",
+	RPL_SASLSUCCESS:       "RPL_SASLSUCCESS",
 	RPL_SERVLIST:          "RPL_SERVLIST",
 	RPL_SERVLISTEND:       "RPL_SERVLISTEND",
 	RPL_STATSCOMMANDS:     "RPL_STATSCOMMANDS",
@@ -243,6 +281,7 @@ var NumericName = map[string]string{
 	RPL_SUMMONING:         "RPL_SUMMONING",
 	RPL_TIME:              "RPL_TIME",
 	RPL_TOPIC:             "RPL_TOPIC",
+	RPL_TOPICWHOTIME:      "RPL_TOPICWHOTIME",
 	RPL_TRACECLASS:        "RPL_TRACECLASS",
 	RPL_TRACECONNECTING:   "RPL_TRACECONNECTING",
 	RPL_TRACEEND:          "RPL_TRACEEND",
@@ -264,9 +303,12 @@ var NumericName = map[string]string{
 	RPL_USERSSTART:        "RPL_USERSSTART",
 	RPL_VERSION:           "RPL_VERSION",
 	RPL_WELCOME:           "RPL_WELCOME",
+	RPL_WHOISACCOUNT:      "RPL_WHOISACCOUNT",
+	RPL_WHOISCERTFP:       "RPL_WHOISCERTFP",
 	RPL_WHOISCHANNELS:     "RPL_WHOISCHANNELS",
 	RPL_WHOISIDLE:         "RPL_WHOISIDLE",
 	RPL_WHOISOPERATOR:     "RPL_WHOISOPERATOR",
+	RPL_WHOISSECURE:       "RPL_WHOISSECURE",
 	RPL_WHOISSERVER:       "RPL_WHOISSERVER",
 	RPL_WHOISUSER:         "RPL_WHOISUSER",
 	RPL_WHOREPLY:          "RPL_WHOREPLY",
@@ -276,7 +318,7 @@ var NumericName = map[string]string{
 	RPL_YOURHOST:          "RPL_YOURHOST",
 }
 
-// Automatically generated from doc/IRC-CustomNumerics.txt doc/IRC-RFC2812.txt
+// Automatically generated from doc/IRC-RFC2812.txt doc/IRC-CustomNumerics.txt
 var NumericText = map[string]string{
 	ERR_ALREADYREGISTRED:  `Unauthorized command (already registered)`,
 	ERR_BADCHANMASK:       `<channel> :Bad Channel Mask`,
@@ -290,13 +332,15 @@ var NumericText = map[string]string{
 	ERR_CHANOPRIVSNEEDED:  `<channel> :You're not channel operator`,
 	ERR_ERRONEUSNICKNAME:  `<nick> :Erroneous nickname`,
 	ERR_FILEERROR:         `File error doing <file op> on <file>`,
+	ERR_INVALIDCAPCMD:     `<subcommand> :Invalid CAP command`,
 	ERR_INVITEONLYCHAN:    `<channel> :Cannot join channel (+i)`,
 	ERR_KEYSET:            `<channel> :Channel key already set`,
+	ERR_MONLISTFULL:       `<limit> <targets> :Monitor list is full`,
 	ERR_NEEDMOREPARAMS:    `<command> :Not enough parameters`,
+	ERR_NEEDREGGEDNICK:    `<channel> :Cannot join channel (+r)`,
 	ERR_NICKCOLLISION:     `<nick> :Nickname collision KILL from <user>@<host>`,
 	ERR_NICKNAMEINUSE:     `<nick> :Nickname is already in use`,
 	ERR_NOADMININFO:       `<server> :No administrative info available`,
-	ERR_NOCHANMODES:       `<channel> :Channel doesn't support modes`,
 	ERR_NOLOGIN:           `<user> :User not logged in`,
 	ERR_NOMOTD:            `MOTD File is missing`,
 	ERR_NONICKNAMEGIVEN:   `No nickname given`,
@@ -315,6 +359,10 @@ var NumericText = map[string]string{
 	ERR_NOTREGISTERED:     `You have not registered`,
 	ERR_PASSWDMISMATCH:    `Password incorrect`,
 	ERR_RESTRICTED:        `Your connection is restricted!`,
+	ERR_SASLABORTED:       `SASL authentication aborted`,
+	ERR_SASLALREADY:       `You have already authenticated using SASL`,
+	ERR_SASLFAIL:          `SASL authentication failed`,
+	ERR_SASLTOOLONG:       `SASL message too long`,
 	ERR_SUMMONDISABLED:    `SUMMON has been disabled`,
 	ERR_TOOMANYCHANNELS:   `<channel name> :You have joined too many channels`,
 	ERR_TOOMANYTARGETS:    `<target> :<error code> recipients. <abort message>`,
@@ -334,15 +382,16 @@ var NumericText = map[string]string{
 	RPL_ADMINME:           `<server> :Administrative info`,
 	RPL_AWAY:              `<nick> :<away message>`,
 	RPL_BANLIST:           `<channel> <banmask>`,
-	RPL_BOUNCE:            `Try server <server name>, port <port number>`,
 	RPL_CHANNELMODEIS:     `<channel> <mode> <mode params>`,
 	RPL_CREATED:           `This server was created <date>`,
+	RPL_CREATIONTIME:      `<channel> <creation time>`,
 	RPL_CUSTOM:            `<param> <param> :Custom Numeric`,
 	RPL_ENDOFBANLIST:      `<channel> :End of channel ban list`,
 	RPL_ENDOFEXCEPTLIST:   `<channel> :End of channel exception list`,
 	RPL_ENDOFINFO:         `End of INFO list`,
 	RPL_ENDOFINVITELIST:   `<channel> :End of channel invite list`,
 	RPL_ENDOFLINKS:        `<mask> :End of LINKS list`,
+	RPL_ENDOFMONLIST:      `End of MONITOR list`,
 	RPL_ENDOFMOTD:         `End of MOTD command`,
 	RPL_ENDOFNAMES:        `<channel> :End of NAMES list`,
 	RPL_ENDOFSTATS:        `<stats letter> :End of STATS report`,
@@ -351,6 +400,7 @@ var NumericText = map[string]string{
 	RPL_ENDOFWHOIS:        `<nick> :End of WHOIS list`,
 	RPL_ENDOFWHOWAS:       `<nick> :End of WHOWAS`,
 	RPL_EXCEPTLIST:        `<channel> <exceptionmask>`,
+	RPL_GLOBALUSERS:       `<current> <max> :Current global users <current>, max <max>`,
 	RPL_INFO:              `<string>`,
 	RPL_INVITELIST:        `<channel> <invitemask>`,
 	RPL_INVITING:          `<channel> <nick>`,
@@ -359,19 +409,28 @@ var NumericText = map[string]string{
 	RPL_LINKS:             `<mask> <server> :<hopcount> <server info>`,
 	RPL_LIST:              `<channel> <# visible> :<topic>`,
 	RPL_LISTEND:           `End of LIST`,
+	RPL_LISTSTART:         `Channel :Users  Name`,
+	RPL_LOCALUSERS:        `<current> <max> :Current local users <current>, max <max>`,
+	RPL_LOGGEDIN:          `<nick!user@host> <account> :You are now logged in`,
+	RPL_LOGGEDOUT:         `<nick!user@host> :You are now logged out`,
 	RPL_LUSERCHANNELS:     `<integer> :channels formed`,
 	RPL_LUSERCLIENT:       `There are <integer> users and <integer> services on <integer> servers`,
 	RPL_LUSERME:           `I have <integer> clients and <integer> servers`,
 	RPL_LUSEROP:           `<integer> :operator(s) online`,
 	RPL_LUSERUNKNOWN:      `<integer> :unknown connection(s)`,
+	RPL_MONLIST:           `<targets>`,
+	RPL_MONOFFLINE:        `<targets>`,
+	RPL_MONONLINE:         `<targets>`,
 	RPL_MOTD:              `- <text>`,
 	RPL_MOTDSTART:         `- <server> Message of the day - `,
-	RPL_MYINFO:            `<servername> <version> <available user modes> <available channel modes>`,
+	RPL_MYINFO:            `<servername> <version> <available user modes> <available channel modes> <channel modes with a parameter>`,
 	RPL_NAMREPLY:          `( "=" / "*" / "@" ) <channel> :[ "@" / "+" ] <nick> *( " " [ "@" / "+" ] <nick> )`,
 	RPL_NOTOPIC:           `<channel> :No topic is set`,
 	RPL_NOUSERS:           `Nobody logged in`,
 	RPL_NOWAWAY:           `You have been marked as being away`,
 	RPL_REHASHING:         `<config file> :Rehashing`,
+	RPL_SASLMECHS:         `<mechanisms> :are available SASL mechanisms`,
+	RPL_SASLSUCCESS:       `SASL authentication successful`,
 	RPL_SERVLIST:          `<name> <server> <mask> <type> <hopcount> <info>`,
 	RPL_SERVLISTEND:       `<mask> <type> :End of service listing`,
 	RPL_STATSCOMMANDS:     `<command> <count> <byte count> <remote count>`,
@@ -381,6 +440,7 @@ var NumericText = map[string]string{
 	RPL_SUMMONING:         `<user> :Summoning user to IRC`,
 	RPL_TIME:              `<server> :<string showing server's local time>`,
 	RPL_TOPIC:             `<channel> :<topic>`,
+	RPL_TOPICWHOTIME:      `<channel> <setter> <time>`,
 	RPL_TRACECLASS:        `Class <class> <count>`,
 	RPL_TRACECONNECTING:   `Try. <class> <server>`,
 	RPL_TRACEEND:          `<server name> <version & debug level> :End of TRACE`,
@@ -402,12 +462,15 @@ var NumericText = map[string]string{
 	RPL_USERSSTART:        `UserID   Terminal  Host`,
 	RPL_VERSION:           `<version>.<debuglevel> <server> :<comments>`,
 	RPL_WELCOME:           `Welcome to the Internet Relay Network <nick>!<user>@<host>`,
+	RPL_WHOISACCOUNT:      `<nick> <account> :is logged in as`,
+	RPL_WHOISCERTFP:       `<nick> :has client certificate fingerprint <fingerprint>`,
 	RPL_WHOISCHANNELS:     `<nick> :*( ( "@" / "+" ) <channel> " " )`,
-	RPL_WHOISIDLE:         `<nick> <integer> :seconds idle`,
+	RPL_WHOISIDLE:         `<nick> <integer> <signon> :seconds idle, signon time`,
 	RPL_WHOISOPERATOR:     `<nick> :is an IRC operator`,
+	RPL_WHOISSECURE:       `<nick> :is using a secure connection`,
 	RPL_WHOISSERVER:       `<nick> <server> :<server info>`,
 	RPL_WHOISUSER:         `<nick> <user> <host> * :<real name>`,
-	RPL_WHOREPLY:          `<channel> <user> <host> <server> <nick> ( "H" / "G" > ["*"] [ ( "@" / "+" ) ] :<hopcount> <real name>`,
+	RPL_WHOREPLY:          `<channel> <user> <host> <server> <nick> <flags> :<hopcount> <real name>`,
 	RPL_WHOWASUSER:        `<nick> <user> <host> * :<real name>`,
 	RPL_YOUREOPER:         `You are now an IRC operator`,
 	RPL_YOURESERVICE:      `You are service <servicename>`,
@@ -0,0 +1,56 @@
+package ircd
+
+// relayNumerics returns hooks which relay the given numerics from linked
+// servers to the users to which they are addressed.
+func relayNumerics(nums ...string) []*Hook {
+	hooks := make([]*Hook, 0, len(nums))
+	for _, num := range nums {
+		hooks = append(hooks, Register(num, EMASK_SERVER, MinArgs(1), SNumeric))
+	}
+	return hooks
+}
+
+// sendToUser sends a message (usually a numeric) from this server to a local
+// or remote user.  Messages to remote users are sent through the link to their
+// server with the user's UID as the first argument.
+func sendToUser(uid string, msg *Message, ircd *IRCd) {
+	if uid[:3] == Config.SID {
+		msg.DestIDs = []string{uid}
+		ircd.ToClient <- msg
+		return
+	}
+	if len(msg.Args) > 0 && msg.Args[0] == "*" {
+		msg.Args[0] = uid
+	}
+	if len(msg.Prefix) == 0 {
+		msg.Prefix = Config.SID
+	}
+	for sid := range IterFor([]string{uid}, "") {
+		fmsg := msg.Dup()
+		fmsg.DestIDs = []string{sid}
+		ircd.ToServer <- fmsg
+	}
+}
+
+// Handle a numeric from a linked server.
+//
+//	:<sid> <numeric> <uid> [<params>...]
+func SNumeric(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.Args[0]
+	if _, _, _, _, ok := GetUserInfo(uid); !ok {
+		Warn.Printf("%s for unknown user %s from %s", hook, uid, msg.SenderID)
+		return
+	}
+	if uid[:3] == Config.SID {
+		fmsg := msg.Dup()
+		fmsg.Prefix = sourceName(msg.Prefix)
+		fmsg.DestIDs = []string{uid}
+		ircd.ToClient <- fmsg
+		return
+	}
+	for sid := range IterFor([]string{uid}, msg.SenderID) {
+		fmsg := msg.Dup()
+		fmsg.DestIDs = []string{sid}
+		ircd.ToServer <- fmsg
+	}
+}
@@ -0,0 +1,381 @@
+package ircd
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+)
+
+var (
+	saslhooks = []*Hook{
+		Register(CMD_AUTHENTICATE, EMASK_REGISTRATION|EMASK_USER, NArgs(1), Authenticate).InOrder(),
+	}
+)
+
+// Clients which have enabled sasl may log in to an account with
+// AUTHENTICATE, usually before they finish registering.
+var capSASL = RegisterCap("sasl", saslMechanisms)
+
+const (
+	// The SASL mechanisms which are checked against Accounts.
+	saslMechanisms = "PLAIN,EXTERNAL"
+
+	// The longest argument to AUTHENTICATE.  Longer data is split into
+	// chunks of this length, followed by + if the last chunk is full.
+	saslChunkLength = 400
+
+	// The most data a client may send for a single authentication.
+	saslMaxLength = 10 * saslChunkLength
+)
+
+// A saslSession is a SASL authentication in progress.  The data and agent
+// are changed by the client's messages and by services, so they are guarded
+// by the mutex.
+type saslSession struct {
+	mutex     *sync.Mutex
+	mechanism string
+	forwarded bool // the authentication is being handled by services
+	data      string
+	agent     string // the services agent handling it (if known)
+}
+
+// newSASLSession returns a new authentication with the mechanism.
+func newSASLSession(mechanism string, forwarded bool) *saslSession {
+	return &saslSession{
+		mutex:     new(sync.Mutex),
+		mechanism: mechanism,
+		forwarded: forwarded,
+	}
+}
+
+// Get the services agent handling the authentication, or * if it is not
+// known.
+func (s *saslSession) Agent() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.agent) == 0 {
+		return "*"
+	}
+	return s.agent
+}
+
+// Set the services agent handling the authentication.
+func (s *saslSession) SetAgent(agent string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.agent = agent
+}
+
+// AddData appends a chunk of data sent by the client and returns all of the
+// data sent so far.
+func (s *saslSession) AddData(chunk string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data += chunk
+	return s.data
+}
+
+// checkSASL checks the data sent by a client with the given mechanism and
+// client certificate fingerprint against the Authenticator and returns the
+// account to which it may log in.
+func checkSASL(auth Authenticator, mechanism, data, fingerprint string) (account string, ok bool) {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", false
+	}
+	switch mechanism {
+	case "PLAIN":
+		// [<authzid>] \0 <authcid> \0 <password>
+		fields := strings.Split(string(raw), "\x00")
+		if len(fields) != 3 {
+			return "", false
+		}
+		account, ok = auth.CheckPassword(fields[1], fields[2])
+		if ok && len(fields[0]) > 0 && !strings.EqualFold(fields[0], account) {
+			return "", false
+		}
+		return account, ok
+	case "EXTERNAL":
+		// [<authzid>]
+		return auth.CheckCertificate(fingerprint, string(raw))
+	}
+	return "", false
+}
+
+// Clients which have enabled account-notify are sent an ACCOUNT when a user
+// who shares a channel with them logs in.
+var capAccountNotify = RegisterCap("account-notify", "")
+
+// notifyAccount tells the local users who share a channel with the user and
+// have enabled account-notify that the user has logged in to the account.
+func notifyAccount(uid, account string, ircd *IRCd) {
+	notify, _ := splitCapUsers(localPeers(uid), capAccountNotify)
+	if len(notify) == 0 {
+		return
+	}
+	ircd.ToClient <- &Message{
+		Prefix:  uid,
+		Command: CMD_ACCOUNT,
+		Args: []string{
+			account,
+		},
+		DestIDs: notify,
+	}
+}
+
+// loginUser logs the local user in to the account and tells them (and, once
+// they have registered, the rest of the network).
+func loginUser(uid, account string, ircd *IRCd) {
+	u := GetUser(uid)
+	u.SetAccount(account)
+	applied, _ := u.ApplyModes([]Mode{{
+		Spec: UserModes['r'],
+		Op:   SetMode,
+	}})
+
+	msg := NewNumeric(RPL_LOGGEDIN, u.Hostmask(), account).Message(uid)
+	msg.Args[3] = "You are now logged in as " + account
+	ircd.ToClient <- msg
+
+	if u.Type() == RegisteredAsUser {
+		announceUserModes(uid, applied, "", ircd)
+		sendEncap(uid, "*", CMD_LOGIN, []string{account}, ircd)
+		notifyAccount(uid, account, ircd)
+	}
+}
+
+// fromServices returns true if the message was sent by the services server
+// (or one of its users) and arrived on the link which leads to it.
+func fromServices(msg *Message) bool {
+	if len(Config.Services) == 0 || len(msg.Prefix) < 3 {
+		return false
+	}
+	sid, ok := ServerByName(Config.Services)
+	if !ok || msg.Prefix[:3] != sid {
+		return false
+	}
+	from := false
+	for link := range IterFor([]string{sid}, "") {
+		from = from || link == msg.SenderID
+	}
+	return from
+}
+
+// sendSASL forwards part of a SASL authentication to services.
+//
+//	:<sid> ENCAP <services> SASL <uid> <agent> <mode> <data> [<data>]
+func sendSASL(uid string, session *saslSession, mode string, data []string, ircd *IRCd) {
+	args := append([]string{uid, session.Agent(), mode}, data...)
+	sendEncap(Config.SID, Config.Services, CMD_SASL, args, ircd)
+}
+
+// startSASL starts an authentication with the mechanism.  If services are
+// configured, it is forwarded to them.
+func startSASL(u *User, mechanism string, ircd *IRCd) {
+	uid := u.ID()
+	if len(u.Account()) > 0 {
+		ircd.ToClient <- NewNumeric(ERR_SASLALREADY).Message(uid)
+		return
+	}
+
+	if len(Config.Services) > 0 {
+		if _, ok := ServerByName(Config.Services); !ok {
+			ircd.ToClient <- NewNumeric(ERR_SASLFAIL).Message(uid)
+			return
+		}
+		session := newSASLSession(mechanism, true)
+		u.setSASLSession(session)
+		sendSASL(uid, session, "H", []string{u.Host(), u.IP()}, ircd)
+		start := []string{mechanism}
+		if fp := u.Fingerprint(); len(fp) > 0 {
+			start = append(start, fp)
+		}
+		sendSASL(uid, session, "S", start, ircd)
+		return
+	}
+
+	if Accounts == nil {
+		ircd.ToClient <- NewNumeric(ERR_SASLFAIL).Message(uid)
+		return
+	}
+	if mechanism != "PLAIN" && mechanism != "EXTERNAL" {
+		ircd.ToClient <- NewNumeric(RPL_SASLMECHS, saslMechanisms).Message(uid)
+		ircd.ToClient <- NewNumeric(ERR_SASLFAIL).Message(uid)
+		return
+	}
+	u.setSASLSession(newSASLSession(mechanism, false))
+	ircd.ToClient <- &Message{
+		Command: CMD_AUTHENTICATE,
+		Args: []string{
+			"+",
+		},
+		DestIDs: []string{uid},
+	}
+}
+
+// abortSASL aborts the user's authentication.
+func abortSASL(u *User, ircd *IRCd) {
+	if session := u.saslSession(); session != nil && session.forwarded {
+		sendSASL(u.ID(), session, "D", []string{"A"}, ircd)
+	}
+	u.setSASLSession(nil)
+	ircd.ToClient <- NewNumeric(ERR_SASLABORTED).Message(u.ID())
+}
+
+// Handle an AUTHENTICATE from a local client.  The first AUTHENTICATE names
+// the mechanism and those which follow carry base64-encoded data.  A client's
+// AUTHENTICATE messages are handled in the order in which they were sent, so
+// that the chunks of data are put together in order.
+//
+//	AUTHENTICATE <mechanism>
+//	AUTHENTICATE <data>|+|*
+func Authenticate(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	u := GetUser(uid)
+	arg := msg.Args[0]
+
+	if !u.HasCap(capSASL) {
+		ircd.ToClient <- NewNumeric(ERR_SASLFAIL).Message(uid)
+		return
+	}
+	if arg == "*" {
+		abortSASL(u, ircd)
+		return
+	}
+
+	session := u.saslSession()
+	if session == nil {
+		startSASL(u, strings.ToUpper(arg), ircd)
+		return
+	}
+
+	if len(arg) > saslChunkLength {
+		if session.forwarded {
+			sendSASL(uid, session, "D", []string{"A"}, ircd)
+		}
+		u.setSASLSession(nil)
+		ircd.ToClient <- NewNumeric(ERR_SASLTOOLONG).Message(uid)
+		return
+	}
+	if session.forwarded {
+		sendSASL(uid, session, "C", []string{arg}, ircd)
+		return
+	}
+
+	chunk := arg
+	if chunk == "+" {
+		chunk = ""
+	}
+	data := session.AddData(chunk)
+	if len(data) > saslMaxLength {
+		u.setSASLSession(nil)
+		ircd.ToClient <- NewNumeric(ERR_SASLTOOLONG).Message(uid)
+		return
+	}
+	if len(arg) == saslChunkLength {
+		// Wait for the rest of the data
+		return
+	}
+
+	u.setSASLSession(nil)
+	account, ok := checkSASL(Accounts, session.mechanism, data, u.Fingerprint())
+	if !ok {
+		ircd.ToClient <- NewNumeric(ERR_SASLFAIL).Message(uid)
+		return
+	}
+	loginUser(uid, account, ircd)
+	ircd.ToClient <- NewNumeric(RPL_SASLSUCCESS).Message(uid)
+}
+
+// Handle an ENCAP SASL from services (and only from services).  C sends data
+// to the client, D ends the authentication (with S for success, F for
+// failure, or A for abort), and M lists the available mechanisms.
+//
+//	:<sid> ENCAP <server> SASL <agent> <uid> <mode> [<data>]
+func SSASL(msg *Message, ircd *IRCd) {
+	if len(msg.Args) < 3 {
+		return
+	}
+	if !fromServices(msg) {
+		Warn.Printf("Dropping SASL from %s via %s: not services", msg.Prefix, msg.SenderID)
+		return
+	}
+	agent, uid, mode := msg.Args[0], msg.Args[1], msg.Args[2]
+	data := ""
+	if len(msg.Args) > 3 {
+		data = msg.Args[3]
+	}
+	if !isuid(uid) || uid[:3] != Config.SID {
+		return
+	}
+	if _, _, _, _, ok := GetUserInfo(uid); !ok {
+		return
+	}
+	u := GetUser(uid)
+	session := u.saslSession()
+	if session == nil || !session.forwarded {
+		return
+	}
+	session.SetAgent(agent)
+
+	switch mode {
+	case "C":
+		ircd.ToClient <- &Message{
+			Command: CMD_AUTHENTICATE,
+			Args: []string{
+				data,
+			},
+			DestIDs: []string{uid},
+		}
+	case "D":
+		u.setSASLSession(nil)
+		switch data {
+		case "S":
+			ircd.ToClient <- NewNumeric(RPL_SASLSUCCESS).Message(uid)
+		case "A":
+			ircd.ToClient <- NewNumeric(ERR_SASLABORTED).Message(uid)
+		default:
+			ircd.ToClient <- NewNumeric(ERR_SASLFAIL).Message(uid)
+		}
+	case "M":
+		ircd.ToClient <- NewNumeric(RPL_SASLMECHS, data).Message(uid)
+	}
+}
+
+// Handle an ENCAP SVSLOGIN from services (and only from services), which
+// logs a local user in to an account.
+//
+//	:<sid> ENCAP <server> SVSLOGIN <uid> <nick> <user> <host> <account>
+func SSvslogin(msg *Message, ircd *IRCd) {
+	if len(msg.Args) < 5 {
+		return
+	}
+	if !fromServices(msg) {
+		Warn.Printf("Dropping SVSLOGIN from %s via %s: not services", msg.Prefix, msg.SenderID)
+		return
+	}
+	uid, account := msg.Args[0], msg.Args[4]
+	if !isuid(uid) || uid[:3] != Config.SID || account == "*" {
+		return
+	}
+	if _, _, _, _, ok := GetUserInfo(uid); !ok {
+		return
+	}
+	loginUser(uid, account, ircd)
+}
+
+// Handle an ENCAP LOGIN from a linked server, which records the account a
+// remote user is logged in to.
+//
+//	:<uid> ENCAP * LOGIN <account>
+func SLogin(msg *Message, ircd *IRCd) {
+	if len(msg.Args) < 1 {
+		return
+	}
+	if _, _, _, _, ok := GetUserInfo(msg.Prefix); !ok {
+		Warn.Printf("LOGIN for unknown user %s from %s", msg.Prefix, msg.SenderID)
+		return
+	}
+	GetUser(msg.Prefix).SetAccount(msg.Args[0])
+	notifyAccount(msg.Prefix, msg.Args[0], ircd)
+}
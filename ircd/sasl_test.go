@@ -0,0 +1,203 @@
+package ircd
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var saslAccounts = &FileAuthenticator{
+	accounts: []*Account{
+		{
+			Name:     "Alice",
+			Password: &Password{Type: PasswordPlain, Password: "secret"},
+			CertFP:   []string{"abcdef"},
+		},
+		{
+			Name:   "bob",
+			CertFP: []string{"abcdef", "123456"},
+		},
+	},
+}
+
+var saslTests = []struct {
+	Mechanism   string
+	Data        string
+	Fingerprint string
+	Account     string
+	OK          bool
+}{
+	{"PLAIN", "\x00alice\x00secret", "", "Alice", true},
+	{"PLAIN", "Alice\x00alice\x00secret", "", "Alice", true},
+	{"PLAIN", "bob\x00alice\x00secret", "", "", false},
+	{"PLAIN", "\x00alice\x00wrong", "", "", false},
+	{"PLAIN", "\x00bob\x00", "", "", false},
+	{"PLAIN", "alice", "", "", false},
+	{"EXTERNAL", "", "ABCDEF", "Alice", true},
+	{"EXTERNAL", "bob", "abcdef", "bob", true},
+	{"EXTERNAL", "alice", "123456", "", false},
+	{"EXTERNAL", "", "", "", false},
+	{"SCRAM-SHA-256", "", "abcdef", "", false},
+}
+
+func TestCheckSASL(t *testing.T) {
+	for idx, test := range saslTests {
+		data := base64.StdEncoding.EncodeToString([]byte(test.Data))
+		account, ok := checkSASL(saslAccounts, test.Mechanism, data, test.Fingerprint)
+		if account != test.Account || ok != test.OK {
+			t.Errorf("#%d: checkSASL(%s, %q) = %q, %v, want %q, %v", idx,
+				test.Mechanism, test.Data, account, ok, test.Account, test.OK)
+		}
+	}
+
+	if _, ok := checkSASL(saslAccounts, "PLAIN", "not base64!", ""); ok {
+		t.Errorf("checkSASL accepted invalid base64")
+	}
+}
+
+var servicesTests = []handlerTest{
+	{
+		Desc:   "login from another server",
+		Hook:   CMD_ENCAP,
+		Func:   Encap,
+		Sender: "1TB",
+		Prefix: "1TB",
+		Args:   []string{"blight.local", "SVSLOGIN", "alice", "*", "*", "*", "mallory"},
+		ToServer: []string{
+			"1TA :1TB ENCAP blight.local SVSLOGIN alice * * * mallory",
+		},
+	},
+	{
+		Desc:   "login from services on the wrong link",
+		Hook:   CMD_ENCAP,
+		Func:   Encap,
+		Sender: "1TB",
+		Prefix: "1SV",
+		Args:   []string{"blight.local", "SVSLOGIN", "alice", "*", "*", "*", "mallory"},
+		ToServer: []string{
+			"1TA :1SV ENCAP blight.local SVSLOGIN alice * * * mallory",
+		},
+	},
+	{
+		Desc:   "SASL success from another server",
+		Hook:   CMD_ENCAP,
+		Func:   Encap,
+		Sender: "1TB",
+		Prefix: "1TBAAAAAA",
+		Args:   []string{"blight.local", "SASL", "1TBAAAAAA", "bob", "D", "S"},
+		ToServer: []string{
+			"1TA :1TBAAAAAA ENCAP blight.local SASL 1TBAAAAAA bob D S",
+		},
+	},
+	{
+		Desc:   "SASL success from services",
+		Hook:   CMD_ENCAP,
+		Func:   Encap,
+		Sender: "1TA",
+		Prefix: "1SVAAAAAA",
+		Args:   []string{"blight.local", "SASL", "1SVAAAAAA", "bob", "D", "S"},
+		ToClient: []string{
+			"bob 903 * :SASL authentication successful",
+		},
+		ToServer: []string{
+			"1TB :1SVAAAAAA ENCAP blight.local SASL 1SVAAAAAA bob D S",
+		},
+	},
+	{
+		Desc:   "login from services",
+		Hook:   CMD_ENCAP,
+		Func:   Encap,
+		Sender: "1TA",
+		Prefix: "1SV",
+		Args:   []string{"blight.local", "SVSLOGIN", "alice", "*", "*", "*", "Wonderland"},
+		ToClient: []string{
+			"alice 900 * alice!alice@alice.example Wonderland :You are now logged in as Wonderland",
+			"alice :alice MODE alice +r",
+		},
+		ToServer: []string{
+			"1TB :1SV ENCAP blight.local SVSLOGIN alice * * * Wonderland",
+			"1TA :alice MODE alice +r",
+			"1TB :alice MODE alice +r",
+			"1TA :alice ENCAP * LOGIN Wonderland",
+			"1TB :alice ENCAP * LOGIN Wonderland",
+		},
+		Unsorted: true,
+	},
+}
+
+func TestServicesOnly(t *testing.T) {
+	testConfig(t)
+	Config.Services = "services.test"
+	testLink(t, "1TA")
+	testLink(t, "1TB")
+	if err := LinkServer("1TA", "1SV", "services.test", "2", "Services"); err != nil {
+		t.Fatalf("LinkServer: %s", err)
+	}
+	alice := testUser(t, Config.SID, "alice")
+	bob := testUser(t, Config.SID, "bob")
+	GetUser(bob).setSASLSession(newSASLSession("PLAIN", true))
+
+	for _, test := range servicesTests[:3] {
+		test.run(t)
+	}
+	if got := GetUser(alice).Account(); got != "" {
+		t.Errorf("account = %q after SVSLOGIN from another server, want none", got)
+	}
+	if GetUser(bob).saslSession() == nil {
+		t.Errorf("SASL ended by another server")
+	}
+	for _, test := range servicesTests[3:] {
+		test.run(t)
+	}
+	if GetUser(bob).saslSession() != nil {
+		t.Errorf("SASL not ended by services")
+	}
+	if got, want := GetUser(alice).Account(), "Wonderland"; got != want {
+		t.Errorf("account = %q after SVSLOGIN from services, want %q", got, want)
+	}
+}
+
+func TestAuthenticateChunks(t *testing.T) {
+	testConfig(t)
+	defer func(accounts Authenticator) { Accounts = accounts }(Accounts)
+	password := strings.Repeat("long password ", 40)
+	Accounts = &FileAuthenticator{
+		accounts: []*Account{{
+			Name:     "carol",
+			Password: &Password{Type: PasswordPlain, Password: password},
+		}},
+	}
+	uid := testUser(t, Config.SID, "carol")
+	u := GetUser(uid)
+	u.SetCaps([]string{capSASL}, nil)
+
+	// The data is sent in chunks of 400 bytes, which must be put together
+	// in order
+	data := base64.StdEncoding.EncodeToString([]byte("\x00carol\x00" + password))
+	args := []string{"PLAIN"}
+	for ; len(data) >= saslChunkLength; data = data[saslChunkLength:] {
+		args = append(args, data[:saslChunkLength])
+	}
+	if len(data) == 0 {
+		data = "+"
+	}
+	args = append(args, data)
+	if len(args) < 3 {
+		t.Fatalf("%d AUTHENTICATE messages, want at least 3", len(args))
+	}
+
+	ircd := testIRCd()
+	for _, arg := range args {
+		DispatchClient(&Message{
+			Command:  CMD_AUTHENTICATE,
+			Args:     []string{arg},
+			SenderID: uid,
+		}, ircd)
+	}
+	// Wait for the AUTHENTICATE hooks to return
+	u.inOrder(func(string, *Message, *IRCd) {})(CMD_AUTHENTICATE, nil, ircd)
+
+	if got, want := u.Account(), "carol"; got != want {
+		t.Errorf("account = %q, want %q (sent %s)", got, want, sentLines(ircd.ToClient))
+	}
+}
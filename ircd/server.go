@@ -63,6 +63,25 @@ func (s *Server) Info() (sid, server, pass string, capab []string) {
 	return s.id, s.server, s.pass, s.capab
 }
 
+// Get whether the server sent the given token in its CAPAB.
+func (s *Server) HasCapab(token string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, capab := range s.capab {
+		if capab == token {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the server's description.
+func (s *Server) Description() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.desc
+}
+
 // Get retrieves and/or creates a server.  If a server is created,
 // it is directly linked to this one.
 func GetServer(id string, create bool) *Server {
@@ -139,6 +158,20 @@ func GetServerInfo(id string) (sid, server string, capab []string, typ servType,
 	return s.id, s.server, s.capab, s.styp, true
 }
 
+// ServerByName returns the SID of the server with the given name.
+func ServerByName(name string) (sid string, ok bool) {
+	servMutex.RLock()
+	defer servMutex.RUnlock()
+
+	lowname := ToLower(name)
+	for id, s := range servMap {
+		if ToLower(s.server) == lowname {
+			return id, true
+		}
+	}
+	return "", false
+}
+
 // ServerIter iterates over all server links
 func ServerIter() <-chan string {
 	servMutex.RLock()
@@ -162,6 +195,27 @@ func ServerIter() <-chan string {
 	return out
 }
 
+// ServerCount returns the number of registered servers which are linked
+// directly to this one and the number on the whole network (not including
+// this one).
+func ServerCount() (local, global int) {
+	servMutex.RLock()
+	defer servMutex.RUnlock()
+
+	for sid, s := range servMap {
+		// Remote servers are only known once they have registered
+		if _, remote := upstream[sid]; remote {
+			global++
+			continue
+		}
+		if s.Type() == RegisteredAsServer {
+			local++
+			global++
+		}
+	}
+	return
+}
+
 // IterFor iterates over the link IDs for all of the ID in the given list.
 // The list may contain SIDs, UIDs, or both.  If the skipLink is given,
 // any servers behind that link will be skipped.
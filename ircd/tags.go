@@ -0,0 +1,316 @@
+package ircd
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	taghooks = []*Hook{
+		Register(CMD_TAGMSG, EMASK_USER|EMASK_SERVER, NArgs(1), Tagmsg),
+	}
+)
+
+// Clients which have enabled message-tags are sent all message tags and may
+// send client-only tags (those prefixed with +) with PRIVMSG, NOTICE, and
+// TAGMSG.
+var capMessageTags = RegisterCap("message-tags", "")
+
+// Clients which have enabled server-time are told when each message was
+// relayed to them.
+var (
+	capServerTime = RegisterCap("server-time", "")
+	tagTime       = RegisterTag("time", capServerTime)
+)
+
+// Clients which have enabled account-tag are told the account of the user
+// who sent each message, if they are logged in.
+var (
+	capAccountTag = RegisterCap("account-tag", "")
+	tagAccount    = RegisterTag("account", capAccountTag)
+)
+
+// Every PRIVMSG and NOTICE is given a unique ID, by which it may be found in
+// the history.
+var tagMsgID = RegisterTag("msgid", capMessageTags)
+
+// Linked servers which send MTAGS in their CAPAB are sent message tags.
+// Other links are sent messages without tags, and no TAGMSG at all.
+const capabMessageTags = "MTAGS"
+
+// The format of the time tag.
+const tagTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// Tags may also be sent to clients which have enabled the capability which
+// introduced them (see RegisterTag).
+var (
+	tagMutex = new(sync.RWMutex)
+	tagCaps  = make(map[string]string) // tagCaps[tag] = capability
+)
+
+// RegisterTag records that the tag may be sent to clients which have enabled
+// the given capability (even if they have not enabled message-tags) and
+// returns the tag.
+func RegisterTag(tag, capability string) string {
+	tagMutex.Lock()
+	defer tagMutex.Unlock()
+	tagCaps[tag] = capability
+	return tag
+}
+
+var tagEscapes = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\:`,
+	" ", `\s`,
+	"\r", `\r`,
+	"\n", `\n`,
+)
+
+// escapeTag escapes a tag value for transmission.
+func escapeTag(value string) string {
+	return tagEscapes.Replace(value)
+}
+
+// unescapeTag reverses escapeTag.  Unknown escapes are replaced by the
+// escaped character, and a trailing backslash is dropped.
+func unescapeTag(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	buf := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			buf = append(buf, value[i])
+			continue
+		}
+		if i++; i == len(value) {
+			break
+		}
+		switch value[i] {
+		case ':':
+			buf = append(buf, ';')
+		case 's':
+			buf = append(buf, ' ')
+		case 'r':
+			buf = append(buf, '\r')
+		case 'n':
+			buf = append(buf, '\n')
+		default:
+			buf = append(buf, value[i])
+		}
+	}
+	return string(buf)
+}
+
+// parseTags parses the tags of a message (without the leading @).  Tags
+// without a value are given an empty value.
+func parseTags(raw string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(raw, ";") {
+		if len(tag) == 0 {
+			continue
+		}
+		pieces := strings.SplitN(tag, "=", 2)
+		value := ""
+		if len(pieces) > 1 {
+			value = unescapeTag(pieces[1])
+		}
+		tags[pieces[0]] = value
+	}
+	return tags
+}
+
+// formatTags formats the tags of a message (without the leading @), sorted
+// by name.
+func formatTags(tags map[string]string) string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if value := tags[name]; len(value) > 0 {
+			names[i] += "=" + escapeTag(value)
+		}
+	}
+	return strings.Join(names, ";")
+}
+
+// clientTags returns the client-only tags from the tags of a message sent by
+// a client.  These are the only tags a client may send to other clients.
+func clientTags(tags map[string]string) map[string]string {
+	var client map[string]string
+	for name, value := range tags {
+		if !strings.HasPrefix(name, "+") {
+			continue
+		}
+		if client == nil {
+			client = make(map[string]string)
+		}
+		client[name] = value
+	}
+	return client
+}
+
+// stampTags returns a copy of the tags of a PRIVMSG or NOTICE with a new
+// msgid and the current time, unless it already has them.
+func stampTags(tags map[string]string) map[string]string {
+	stamped := make(map[string]string, len(tags)+2)
+	for name, value := range tags {
+		stamped[name] = value
+	}
+	if _, ok := stamped[tagMsgID]; !ok {
+		stamped[tagMsgID] = randomID(16)
+	}
+	if _, ok := stamped[tagTime]; !ok {
+		stamped[tagTime] = time.Now().UTC().Format(tagTimeFormat)
+	}
+	return stamped
+}
+
+// relayTags returns the tags with which a message is relayed to clients: its
+// own tags, the time it was relayed (unless it came with a time), and the
+// account of the user who sent it.
+func relayTags(msg *Message) map[string]string {
+	tags := make(map[string]string, len(msg.Tags)+2)
+	for name, value := range msg.Tags {
+		tags[name] = value
+	}
+	if _, ok := tags[tagTime]; !ok {
+		tags[tagTime] = time.Now().UTC().Format(tagTimeFormat)
+	}
+	if isuid(msg.Prefix) {
+		if _, _, _, _, ok := GetUserInfo(msg.Prefix); ok {
+			if account := GetUser(msg.Prefix).Account(); len(account) > 0 {
+				tags[tagAccount] = account
+			}
+		}
+	}
+	return tags
+}
+
+// userTags returns the tags of a message which may be sent to the user.
+func userTags(uid string, tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	u := GetUser(uid)
+	if u.HasCap(capMessageTags) {
+		return tags
+	}
+
+	tagMutex.RLock()
+	defer tagMutex.RUnlock()
+	var allowed map[string]string
+	for name, value := range tags {
+		if capability, ok := tagCaps[name]; !ok || !u.HasCap(capability) {
+			continue
+		}
+		if allowed == nil {
+			allowed = make(map[string]string)
+		}
+		allowed[name] = value
+	}
+	return allowed
+}
+
+// serverTags returns the tags of a message which may be sent to the link.
+func serverTags(sid string, tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	if s := GetServer(sid, false); s == nil || !s.HasCapab(capabMessageTags) {
+		return nil
+	}
+	return tags
+}
+
+// Handle a TAGMSG from a local client or a linked server.  The message is
+// only delivered to clients which have enabled message-tags.
+//
+//	@<tags> TAGMSG <target>{,<target>}
+func Tagmsg(hook string, msg *Message, ircd *IRCd) {
+	sender, fromClient := msg.SenderID, len(msg.SenderID) != 3
+	if !fromClient {
+		sender = msg.Prefix
+	}
+	tags := msg.Tags
+	if fromClient {
+		tags = clientTags(msg.Tags)
+	}
+	if len(tags) == 0 {
+		return
+	}
+
+	for _, name := range strings.Split(msg.Args[0], ",") {
+		var recipients []string
+		target := name
+		if ValidChannel(name) {
+			channel, err := GetChannel(name, false)
+			if err == nil && fromClient {
+				err = channel.CanSend(sender)
+			}
+			if num, ok := err.(*Numeric); ok {
+				if fromClient {
+					ircd.ToClient <- num.Message(sender)
+				}
+				continue
+			}
+			for _, uid := range channel.UserIDs() {
+				if uid != sender {
+					recipients = append(recipients, uid)
+				}
+			}
+			target = channel.Name()
+		} else {
+			id, err := GetID(name)
+			if num, ok := err.(*Numeric); ok {
+				if fromClient {
+					ircd.ToClient <- num.Message(sender)
+				}
+				continue
+			}
+			recipients, target = []string{id}, id
+		}
+
+		if fromClient && GetUser(sender).HasCap(capEchoMessage) {
+			recipients = append(recipients, sender)
+		}
+
+		local, remote := []string{}, []string{}
+		for _, uid := range recipients {
+			if uid[:3] == Config.SID {
+				local = append(local, uid)
+			} else {
+				remote = append(remote, uid)
+			}
+		}
+		if local, _ = splitCapUsers(local, capMessageTags); len(local) > 0 {
+			ircd.ToClient <- &Message{
+				Tags:    tags,
+				Prefix:  sender,
+				Command: hook,
+				Args: []string{
+					target,
+				},
+				DestIDs: local,
+			}
+		}
+		for sid := range IterFor(remote, msg.SenderID) {
+			if len(serverTags(sid, tags)) == 0 {
+				continue
+			}
+			ircd.ToServer <- &Message{
+				Tags:    tags,
+				Prefix:  sender,
+				Command: hook,
+				Args: []string{
+					target,
+				},
+				DestIDs: []string{sid},
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package ircd
+
+import (
+	"testing"
+	"time"
+)
+
+var tagEscapeTests = []struct {
+	Value   string
+	Escaped string
+}{
+	{"plain", "plain"},
+	{"a;b c", `a\:b\sc`},
+	{`back\slash`, `back\\slash`},
+	{"cr\rlf\n", `cr\rlf\n`},
+}
+
+func TestEscapeTag(t *testing.T) {
+	for idx, test := range tagEscapeTests {
+		if got, want := escapeTag(test.Value), test.Escaped; got != want {
+			t.Errorf("#%d: escapeTag(%q) = %q, want %q", idx, test.Value, got, want)
+		}
+		if got, want := unescapeTag(test.Escaped), test.Value; got != want {
+			t.Errorf("#%d: unescapeTag(%q) = %q, want %q", idx, test.Escaped, got, want)
+		}
+	}
+
+	// Unknown escapes and trailing backslashes
+	if got, want := unescapeTag(`a\bc\`), "abc"; got != want {
+		t.Errorf("unescapeTag(%q) = %q, want %q", `a\bc\`, got, want)
+	}
+}
+
+var tagMessageTests = []struct {
+	Line    string
+	Tags    map[string]string
+	Command string
+	Args    []string
+}{
+	{
+		Line:    "@+example.org/a=1;b PRIVMSG #chan :hi there",
+		Tags:    map[string]string{"+example.org/a": "1", "b": ""},
+		Command: "PRIVMSG",
+		Args:    []string{"#chan", "hi there"},
+	},
+	{
+		Line:    `@+x=a\sb\:c;time=2012-06-30T23:59:60.419Z :nick!user@host PRIVMSG nick :hello there`,
+		Tags:    map[string]string{"time": "2012-06-30T23:59:60.419Z", "+x": "a b;c"},
+		Command: "PRIVMSG",
+		Args:    []string{"nick", "hello there"},
+	},
+}
+
+func TestTagMessage(t *testing.T) {
+	for idx, test := range tagMessageTests {
+		m := ParseMessage([]byte(test.Line))
+		if m == nil {
+			t.Errorf("#%d: ParseMessage(%q) = nil", idx, test.Line)
+			continue
+		}
+		if got, want := len(m.Tags), len(test.Tags); got != want {
+			t.Errorf("#%d: %d tags, want %d", idx, got, want)
+		}
+		for name, want := range test.Tags {
+			if got, ok := m.Tags[name]; !ok || got != want {
+				t.Errorf("#%d: tag %q = %q, want %q", idx, name, got, want)
+			}
+		}
+		if got, want := m.Command, test.Command; got != want {
+			t.Errorf("#%d: command = %q, want %q", idx, got, want)
+		}
+		if got, want := len(m.Args), len(test.Args); got != want {
+			t.Errorf("#%d: %d args, want %d", idx, got, want)
+		}
+		if got, want := m.String(), test.Line; got != want {
+			t.Errorf("#%d: String() = %q, want %q", idx, got, want)
+		}
+	}
+
+	if m := ParseMessage([]byte("@a=b")); m != nil {
+		t.Errorf("ParseMessage of tags alone = %v, want nil", m)
+	}
+}
+
+func TestClientTags(t *testing.T) {
+	tags := clientTags(map[string]string{"+a": "1", "time": "now", "+b": ""})
+	if got, want := formatTags(tags), "+a=1;+b"; got != want {
+		t.Errorf("clientTags = %q, want %q", got, want)
+	}
+}
+
+func TestRelayTags(t *testing.T) {
+	uid := NextUserID()
+	GetUser(uid).SetAccount("acct")
+	msg := &Message{
+		Tags:    map[string]string{"+a": "1"},
+		Prefix:  uid,
+		Command: "PRIVMSG",
+	}
+	tags := relayTags(msg)
+	if got, want := tags[tagAccount], "acct"; got != want {
+		t.Errorf("account = %q, want %q", got, want)
+	}
+	if _, err := time.Parse(tagTimeFormat, tags[tagTime]); err != nil {
+		t.Errorf("time = %q: %s", tags[tagTime], err)
+	}
+	if _, ok := msg.Tags[tagTime]; ok {
+		t.Errorf("relayTags modified the message tags")
+	}
+
+	// Times from other servers are kept
+	msg.Tags[tagTime] = "2012-06-30T23:59:60.419Z"
+	if got, want := relayTags(msg)[tagTime], msg.Tags[tagTime]; got != want {
+		t.Errorf("time = %q, want %q", got, want)
+	}
+}
+
+var serverTagTests = []handlerTest{
+	{
+		Desc:   "tag message to a link with tags",
+		Hook:   CMD_TAGMSG,
+		Func:   Tagmsg,
+		Sender: "alice",
+		Tags:   map[string]string{"+typing": "active"},
+		Args:   []string{"carol"},
+		ToServer: []string{
+			"1TA @+typing=active :alice TAGMSG carol",
+		},
+	},
+	{
+		Desc:   "tag message to a link without tags",
+		Hook:   CMD_TAGMSG,
+		Func:   Tagmsg,
+		Sender: "alice",
+		Tags:   map[string]string{"+typing": "active"},
+		Args:   []string{"dave"},
+	},
+	{
+		Desc:   "private messages",
+		Hook:   CMD_PRIVMSG,
+		Func:   Privmsg,
+		Sender: "alice",
+		Tags:   map[string]string{"+a": "1"},
+		Args:   []string{"carol,dave", "hi"},
+		ToServer: []string{
+			"1TA @+a=1;msgid=*;time=* :alice PRIVMSG carol hi",
+			"1TB :alice PRIVMSG dave hi",
+		},
+	},
+	{
+		Desc:   "channel message",
+		Hook:   CMD_PRIVMSG,
+		Func:   Privmsg,
+		Sender: "alice",
+		Tags:   map[string]string{"+a": "1"},
+		Args:   []string{"#tags", "hi"},
+		ToServer: []string{
+			"1TA @+a=1;msgid=*;time=* :alice PRIVMSG #tags hi",
+			"1TB :alice PRIVMSG #tags hi",
+		},
+		Unsorted: true,
+	},
+}
+
+func TestServerTags(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	testLink(t, "1TB")
+	GetServer("1TA", false).SetCapab("QS ENCAP " + capabMessageTags)
+	GetServer("1TB", false).SetCapab("QS ENCAP")
+	alice := testUser(t, Config.SID, "alice")
+	channel, _ := GetChannel("#tags", true)
+	channel.Join(alice, testUser(t, "1TA", "carol"), testUser(t, "1TB", "dave"))
+
+	for _, test := range serverTagTests {
+		test.run(t)
+	}
+}
+
+func TestForgedTagmsg(t *testing.T) {
+	testConfig(t)
+	testLink(t, "1TA")
+	testUser(t, Config.SID, "mallory")
+	testUser(t, "1TA", "carol")
+	vera := testUser(t, Config.SID, "vera")
+	GetUser(vera).SetCaps([]string{capMessageTags}, nil)
+
+	test := handlerTest{
+		Desc:   "tag message with a forged prefix",
+		Hook:   CMD_TAGMSG,
+		Func:   Tagmsg,
+		Sender: "mallory",
+		Prefix: "carol",
+		Tags:   map[string]string{"+typing": "active", tagAccount: "admin"},
+		Args:   []string{"vera"},
+		ToClient: []string{
+			"vera @+typing=active :mallory TAGMSG vera",
+		},
+	}
+	test.run(t)
+}
@@ -0,0 +1,154 @@
+package ircd
+
+import (
+	"strings"
+)
+
+var (
+	topichooks = []*Hook{
+		Register(CMD_TOPIC, EMASK_USER, OptArgs(1, 1), Topic),
+		Register(CMD_TOPIC, EMASK_SERVER, NArgs(2), STopic),
+		Register(CMD_TB, EMASK_SERVER, OptArgs(3, 1), TB),
+	}
+)
+
+// sendTopic sends the channel topic (if one is set) to the user.  If always
+// is true, RPL_NOTOPIC is sent if there is no topic.
+func sendTopic(uid string, channel *Channel, always bool, ircd *IRCd) {
+	topic, setter, ts := channel.Topic()
+	if len(topic) == 0 {
+		if always {
+			ircd.ToClient <- NewNumeric(RPL_NOTOPIC, channel.Name()).Message(uid)
+		}
+		return
+	}
+	msg := NewNumeric(RPL_TOPIC, channel.Name()).Message(uid)
+	msg.Args[2] = topic
+	ircd.ToClient <- msg
+	ircd.ToClient <- NewNumeric(RPL_TOPICWHOTIME, channel.Name(), setter, ts).Message(uid)
+}
+
+// notifyTopic notifies the local members of a channel of a topic change.
+func notifyTopic(source string, channel *Channel, ircd *IRCd) {
+	local := localIDs(channel.UserIDs())
+	if len(local) == 0 {
+		return
+	}
+	topic, _, _ := channel.Topic()
+	ircd.ToClient <- &Message{
+		Prefix:  sourceName(source),
+		Command: CMD_TOPIC,
+		Args: []string{
+			channel.Name(),
+			topic,
+		},
+		DestIDs: local,
+	}
+}
+
+// topicSetter returns the name recorded as the setter of a topic set by the
+// given source.
+func topicSetter(source string) string {
+	if len(source) == 3 {
+		return sourceName(source)
+	}
+	return GetUser(source).Hostmask()
+}
+
+// Handle a TOPIC from a local client.
+//
+//	TOPIC <channel> [:<topic>]
+func Topic(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	channel, err := GetChannel(msg.Args[0], false)
+	if num, ok := err.(*Numeric); ok {
+		ircd.ToClient <- num.Message(uid)
+		return
+	}
+
+	if len(msg.Args) == 1 {
+		if channel.HasMode('s') && !channel.OnChan(uid) {
+			ircd.ToClient <- NewNumeric(ERR_NOTONCHANNEL, channel.Name()).Message(uid)
+			return
+		}
+		sendTopic(uid, channel, true, ircd)
+		return
+	}
+
+	if !channel.OnChan(uid) {
+		ircd.ToClient <- NewNumeric(ERR_NOTONCHANNEL, channel.Name()).Message(uid)
+		return
+	}
+	if channel.HasMode('t') && !strings.ContainsAny(channel.Status(uid), "@%") {
+		ircd.ToClient <- NewNumeric(ERR_CHANOPRIVSNEEDED, channel.Name()).Message(uid)
+		return
+	}
+
+	topic := truncate(msg.Args[1], MaxTopicLength)
+	channel.SetTopic(topic, topicSetter(uid))
+	notifyTopic(uid, channel, ircd)
+
+	for sid := range ServerIter() {
+		ircd.ToServer <- &Message{
+			Prefix:  uid,
+			Command: CMD_TOPIC,
+			Args: []string{
+				channel.Name(),
+				topic,
+			},
+			DestIDs: []string{sid},
+		}
+	}
+}
+
+// Handle a TOPIC from a linked server.
+//
+//	:<uid> TOPIC <channel> :<topic>
+func STopic(hook string, msg *Message, ircd *IRCd) {
+	channel, err := GetChannel(msg.Args[0], false)
+	if err != nil {
+		Warn.Printf("TOPIC for unknown channel %s from %s", msg.Args[0], msg.SenderID)
+		return
+	}
+
+	channel.SetTopic(msg.Args[1], topicSetter(msg.Prefix))
+	notifyTopic(msg.Prefix, channel, ircd)
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			fmsg := msg.Dup()
+			fmsg.DestIDs = []string{sid}
+			ircd.ToServer <- fmsg
+		}
+	}
+}
+
+// Handle a TB (topic burst) from a linked server.
+//
+//	:<sid> TB <channel> <topic ts> [<topic setter>] :<topic>
+func TB(hook string, msg *Message, ircd *IRCd) {
+	name, ts := msg.Args[0], msg.Args[1]
+	setter, topic := sourceName(msg.Prefix), msg.Args[len(msg.Args)-1]
+	if len(msg.Args) == 4 {
+		setter = msg.Args[2]
+	}
+
+	channel, err := GetChannel(name, false)
+	if err != nil {
+		Warn.Printf("TB for unknown channel %s from %s", name, msg.SenderID)
+		return
+	}
+	if !channel.BurstTopic(topic, setter, ts) {
+		Debug.Printf("Ignoring TB for %s with TS %s", name, ts)
+		return
+	}
+	notifyTopic(msg.Prefix, channel, ircd)
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			fmsg := msg.Dup()
+			fmsg.DestIDs = []string{sid}
+			ircd.ToServer <- fmsg
+		}
+	}
+}
@@ -2,6 +2,7 @@ package ircd
 
 import (
 	"errors"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,6 +23,9 @@ var (
 	// The server prefix for all user IDs.  Set this before calling
 	// NextUserID.
 	UserIDPrefix = "000"
+
+	// The modes set on a local user when they register.
+	DefaultUserModes = "+i"
 )
 
 type userType int
@@ -53,14 +57,32 @@ func genUserIDs() {
 // Store the user information and keep it synchronized across possible
 // multiple accesses.
 type User struct {
-	mutex *sync.RWMutex
-	ts    time.Time
-	id    string
-	user  string
-	pass  string
-	nick  string
-	name  string
-	utyp  userType
+	mutex  *sync.RWMutex
+	ts     time.Time
+	id     string
+	user   string
+	pass   string
+	nick   string
+	name   string
+	host   string
+	vhost  string
+	ip     string
+	ident  string
+	tilde  bool
+	hops   int
+	signon time.Time
+	active time.Time
+	away   string
+	acct   string
+	caps   map[string]bool
+	capver int
+	capneg bool
+	capend bool
+	order  chan bool
+	sasl   *saslSession
+	utyp   userType
+	modes  ActiveModes
+	certfp string
 }
 
 // Get the user ID.
@@ -87,16 +109,264 @@ func (u *User) Name() string {
 	return u.name
 }
 
-// Get the user's registration type (immutable).
+// Get the user's real hostname.
+func (u *User) Host() string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.host
+}
+
+// Get the user's IP address.
+func (u *User) IP() string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.ip
+}
+
+// Get the hostname shown to other users.
+func (u *User) VisibleHost() string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.vhost
+}
+
+// Set the user's real hostname and IP address.  The visible hostname is
+// also set to the real hostname.
+func (u *User) SetHost(host, ip string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.host, u.vhost, u.ip = host, host, ip
+}
+
+// Get the user's registration type.
 func (u *User) Type() userType {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
 	return u.utyp
 }
 
-// Get the channel TS (comes as a string)
+// Get the number of server hops to the user (0 for local users).
+func (u *User) Hops() int {
+	return u.hops
+}
+
+// Get the time at which the user registered.
+func (u *User) Signon() time.Time {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.signon
+}
+
+// Get how long it has been since the (local) user last sent a message.
+func (u *User) Idle() time.Duration {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return time.Since(u.active)
+}
+
+// Mark the user as active now.
+func (u *User) SetActive() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.active = time.Now()
+}
+
+// Get the user's away message, which is empty if they are not away.
+func (u *User) Away() string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.away
+}
+
+// Set the user's away message.  An empty message marks them as no longer
+// away.
+func (u *User) SetAway(message string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.away = message
+}
+
+// Get the name of the account the user is logged in to, which is empty if
+// they are not logged in.
+func (u *User) Account() string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.acct
+}
+
+// Set the account the user is logged in to.  An empty name logs them out.
+func (u *User) SetAccount(account string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.acct = account
+}
+
+// Get the user's SASL authentication in progress (if any).
+func (u *User) saslSession() *saslSession {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.sasl
+}
+
+// Set the user's SASL authentication in progress.  A nil session ends it.
+func (u *User) setSASLSession(session *saslSession) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.sasl = session
+}
+
+// Get whether the user has enabled the given client capability.
+func (u *User) HasCap(name string) bool {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.caps[name]
+}
+
+// Get the client capabilities the user has enabled, sorted by name.
+func (u *User) Caps() []string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	caps := make([]string, 0, len(u.caps))
+	for name := range u.caps {
+		caps = append(caps, name)
+	}
+	sort.Strings(caps)
+	return caps
+}
+
+// Enable and disable client capabilities for the user.
+func (u *User) SetCaps(enable, disable []string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.caps == nil {
+		u.caps = make(map[string]bool)
+	}
+	for _, name := range enable {
+		u.caps[name] = true
+	}
+	for _, name := range disable {
+		delete(u.caps, name)
+	}
+}
+
+// Get the CAP LS version given by the client, or 0 if none was given.
+func (u *User) CapVersion() int {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.capver
+}
+
+// Record the CAP LS version given by the client.  The version never
+// decreases.
+func (u *User) SetCapVersion(version int) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if version > u.capver {
+		u.capver = version
+	}
+}
+
+// Get whether capability negotiation is holding up the user's registration.
+func (u *User) Negotiating() bool {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.capneg
+}
+
+// Set whether capability negotiation is in progress.  Once negotiation has
+// ended, it cannot start again.
+func (u *User) SetNegotiating(negotiating bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if negotiating && u.capend {
+		return
+	}
+	u.capneg = negotiating
+	u.capend = !negotiating
+}
+
+// inOrder returns a function which calls fn once every function previously
+// returned by inOrder for the user has returned.  It is called as messages
+// from the user are dispatched, so that ordered hooks see them in order.
+func (u *User) inOrder(fn func(string, *Message, *IRCd)) func(string, *Message, *IRCd) {
+	u.mutex.Lock()
+	prev, done := u.order, make(chan bool)
+	u.order = done
+	u.mutex.Unlock()
+
+	return func(hook string, msg *Message, ircd *IRCd) {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		fn(hook, msg, ircd)
+	}
+}
+
+// Get whether the user has the given user mode set.
+func (u *User) HasMode(ch rune) bool {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	isset, _ := u.modes.Get(ch)
+	return isset
+}
+
+// Get the user's modes as a mode string.
+func (u *User) Modes() string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	modes := make([]Mode, 0, len(u.modes))
+	for _, m := range u.modes {
+		m.Op = SetMode
+		modes = append(modes, m)
+	}
+	sort.Sort(modeSort(modes))
+	if len(modes) == 0 {
+		return "+"
+	}
+	return ModeString(modes)
+}
+
+// ApplyModes applies the user mode changes to the user.
+func (u *User) ApplyModes(changes []Mode) (applied []Mode, errors []error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.modes.Apply(changes)
+}
+
+// Get the fingerprint of the user's client certificate (if any).
+func (u *User) Fingerprint() string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.certfp
+}
+
+// SetSecure marks the user as connected over SSL (user mode +Z) with the
+// given client certificate fingerprint (which may be empty).
+func (u *User) SetSecure(fingerprint string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.certfp = fingerprint
+	u.modes.Apply([]Mode{{
+		Spec: UserModes['Z'],
+		Op:   SetMode,
+	}})
+}
+
+// Get the user's nick!user@host mask.
+func (u *User) Hostmask() string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.nick + "!" + u.user + "@" + u.vhost
+}
+
+// Get the nick TS (comes as a string)
 func (u *User) TS() string {
 	u.mutex.RLock()
 	defer u.mutex.RUnlock()
-	return strconv.FormatInt(int64(u.ts.Second()), 10)
+	return strconv.FormatInt(u.ts.Unix(), 10)
 }
 
 // Atomically get all of the user's information.
@@ -106,37 +376,76 @@ func (u *User) Info() (nick, user, name string, regType userType) {
 	return u.nick, u.user, u.name, u.utyp
 }
 
-// Set the user's nick.
+// Set the user's nick.  Changing only the case of the nick is allowed.
 func (u *User) SetNick(nick string) error {
+	return u.setNick(nick, time.Now())
+}
+
+// Set the nick of a remote user, along with the nick TS given by its server.
+func (u *User) SetRemoteNick(nick, ts string) error {
+	its, _ := strconv.ParseInt(ts, 10, 64)
+	return u.setNick(nick, time.Unix(its, 0))
+}
+
+func (u *User) setNick(nick string, ts time.Time) error {
 	if !ValidNick(nick) {
 		return NewNumeric(ERR_ERRONEUSNICKNAME, nick)
 	}
 
 	lownick := ToLower(nick)
+	id := u.ID()
 
 	userMutex.Lock()
 	defer userMutex.Unlock()
 
-	if id, used := userNicks[lownick]; used {
-		if id == u.ID() {
-			return nil
-		}
+	if other, used := userNicks[lownick]; used && other != id {
 		return NewNumeric(ERR_NICKNAMEINUSE, nick)
 	}
-	userNicks[lownick] = u.ID()
-
-	lownick = ToLower(u.nick)
-	delete(userNicks, lownick)
 
 	u.mutex.Lock()
 	defer u.mutex.Unlock()
 
+	if u.nick == nick {
+		return nil
+	}
+	// The nick TS is kept when only the case changes
+	if ToLower(u.nick) != lownick {
+		u.ts = ts
+	}
+	delete(userNicks, ToLower(u.nick))
+	userNicks[lownick] = id
+
 	u.nick = nick
-	u.ts = time.Now()
 	return nil
 }
 
-// Set the user and gecos (immutable once set).
+// releaseNick frees the nick of a user who is being removed (e.g. after a
+// nick collision) so that another user can take it before they are deleted.
+func releaseNick(id string) {
+	userMutex.Lock()
+	defer userMutex.Unlock()
+
+	if u, ok := userMap[id]; ok {
+		u.mutex.RLock()
+		defer u.mutex.RUnlock()
+
+		if nick := ToLower(u.nick); userNicks[nick] == id {
+			delete(userNicks, nick)
+		}
+	}
+}
+
+// Record the result of an ident lookup (before the user is set).  If the
+// lookup failed, ident is empty and the username given by the client will be
+// prefixed with a ~.
+func (u *User) SetIdent(ident string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.ident, u.tilde = ident, len(ident) == 0
+}
+
+// Set the user and gecos (immutable once set).  If an ident lookup was
+// performed, its result is used in place of the given username.
 func (u *User) SetUser(user, name string) error {
 	if len(u.user) > 0 {
 		return NewNumeric(ERR_ALREADYREGISTRED)
@@ -150,6 +459,12 @@ func (u *User) SetUser(user, name string) error {
 	u.mutex.Lock()
 	defer u.mutex.Unlock()
 
+	switch {
+	case len(u.ident) > 0:
+		user = u.ident
+	case u.tilde:
+		user = "~" + user
+	}
 	u.user, u.name = user, name
 	u.ts = time.Now()
 	return nil
@@ -157,11 +472,15 @@ func (u *User) SetUser(user, name string) error {
 
 // Set the user's type (immutable once set).
 func (u *User) SetType(newType userType) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
 	if u.utyp != UnregisteredUser {
 		return NewNumeric(ERR_ALREADYREGISTRED)
 	}
 	u.utyp = newType
 	u.ts = time.Now()
+	u.signon, u.active = u.ts, u.ts
 	return nil
 }
 
@@ -217,6 +536,7 @@ func GetUser(id string) *User {
 		mutex: new(sync.RWMutex),
 		id:    id,
 		nick:  "*",
+		modes: make(ActiveModes),
 	}
 
 	userMap[id] = u
@@ -225,6 +545,8 @@ func GetUser(id string) *User {
 
 // Delete the user record.
 func Delete(id string) {
+	clearMonitor(id)
+
 	userMutex.Lock()
 	defer userMutex.Unlock()
 
@@ -233,8 +555,10 @@ func Delete(id string) {
 		u.mutex.RLock()
 		defer u.mutex.RUnlock()
 
-		nick := strings.ToLower(u.nick)
-		delete(userNicks, nick)
+		// The nick may have been released and taken by another user
+		if nick := strings.ToLower(u.nick); userNicks[nick] == id {
+			delete(userNicks, nick)
+		}
 		delete(userMap, id)
 	}
 }
@@ -258,6 +582,35 @@ func UserIter() <-chan string {
 	return out
 }
 
+// UserCount returns the number of registered users on this server and on the
+// whole network, the number of those which are invisible and IRC operators,
+// and the number of local connections which have not yet registered.
+func UserCount() (local, global, invisible, opers, unknown int) {
+	userMutex.RLock()
+	defer userMutex.RUnlock()
+
+	for _, u := range userMap {
+		isLocal := u.id[:3] == Config.SID
+		if u.Type() != RegisteredAsUser {
+			if isLocal {
+				unknown++
+			}
+			continue
+		}
+		global++
+		if isLocal {
+			local++
+		}
+		if u.HasMode('i') {
+			invisible++
+		}
+		if u.HasMode('o') {
+			opers++
+		}
+	}
+	return
+}
+
 // UserSplit returns the list of users who will be unreachable when the given list
 // of servers are split from the network.  It does not actually delete them.
 func UserSplit(sids []string) (splitIDs []string) {
@@ -277,7 +630,7 @@ func UserSplit(sids []string) (splitIDs []string) {
 	return
 }
 
-func Import(uid, nick, user, host, ip, hops, ts, name string) error {
+func Import(uid, nick, user, host, ip, hops, ts, umode, name string) error {
 	userMutex.Lock()
 	defer userMutex.Unlock()
 
@@ -292,15 +645,23 @@ func Import(uid, nick, user, host, ip, hops, ts, name string) error {
 	}
 
 	its, _ := strconv.ParseInt(ts, 10, 64)
+	ihops, _ := strconv.Atoi(hops)
 	u := &User{
 		mutex: new(sync.RWMutex),
-		ts:    time.Unix(0, its),
+		ts:    time.Unix(its, 0),
 		id:    uid,
 		user:  user,
 		nick:  nick,
 		name:  name,
+		host:  host,
+		vhost: host,
+		ip:    ip,
+		hops:  ihops,
 		utyp:  RegisteredAsUser,
+		modes: make(ActiveModes),
 	}
+	changes, _ := ParseModeChange([]string{umode}, UserModes)
+	u.modes.Apply(changes)
 
 	userMap[uid] = u
 	userNicks[lownick] = uid
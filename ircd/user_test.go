@@ -80,11 +80,18 @@ var nickSetTests = []struct {
 		Count: 2,
 		After: "NewNick",
 	},
+	{
+		Nick:  "NEWNICK",
+		Error: nil,
+		Count: 2,
+		After: "NEWNICK",
+	},
 }
 
 func TestSetNick(t *testing.T) {
 	// Set up the dummy user
 	dummy := GetUser(NextUserID())
+	defer Delete(dummy.ID())
 	err := dummy.SetNick(dummyNick)
 	if err != nil {
 		t.Fatalf("dummy.SetNick(%s) returned %s", dummyNick, err)
@@ -95,6 +102,7 @@ func TestSetNick(t *testing.T) {
 
 	// Set up victim user
 	victim := GetUser(NextUserID())
+	defer Delete(victim.ID())
 
 	for idx, test := range nickSetTests {
 		err := victim.SetNick(test.Nick)
@@ -110,6 +118,70 @@ func TestSetNick(t *testing.T) {
 	}
 }
 
+func TestSetRemoteNick(t *testing.T) {
+	u := GetUser(NextUserID())
+	defer Delete(u.ID())
+	if err := u.SetRemoteNick("Remote", "1234567890"); err != nil {
+		t.Fatalf("SetRemoteNick = %s", err)
+	}
+	if got, want := u.Nick(), "Remote"; got != want {
+		t.Errorf("nick = %q, want %q", got, want)
+	}
+	if got, want := u.TS(), "1234567890"; got != want {
+		t.Errorf("ts = %q, want %q", got, want)
+	}
+
+	// Changing only the case keeps the nick TS
+	if err := u.SetRemoteNick("REMOTE", "1234567899"); err != nil {
+		t.Fatalf("SetRemoteNick = %s", err)
+	}
+	if got, want := u.TS(), "1234567890"; got != want {
+		t.Errorf("ts after case change = %q, want %q", got, want)
+	}
+}
+
+var userModeTests = []struct {
+	Apply  string
+	Result string
+}{
+	{"+wi", "+iw"},
+	{"+Z-w", "+Zi"},
+	{"-iZ", "+"},
+}
+
+func TestUserModes(t *testing.T) {
+	u := GetUser(NextUserID())
+	for idx, test := range userModeTests {
+		changes, _ := ParseModeChange([]string{test.Apply}, UserModes)
+		u.ApplyModes(changes)
+		if got, want := u.Modes(), test.Result; got != want {
+			t.Errorf("#%d: after %q, modes = %q, want %q", idx, test.Apply, got, want)
+		}
+	}
+}
+
+func TestUserCount(t *testing.T) {
+	testConfig(t)
+	uid := NextUserID()
+	defer Delete(uid)
+	u := GetUser(uid)
+	u.SetNick("Counted")
+
+	// Users may register while they are being counted
+	done := make(chan bool)
+	go func() {
+		u.SetType(RegisteredAsUser)
+		close(done)
+	}()
+	UserCount()
+	<-done
+
+	local, global, _, _, _ := UserCount()
+	if local < 1 || global < local {
+		t.Errorf("UserCount() = %d local, %d global; want at least 1 local", local, global)
+	}
+}
+
 func BenchmarkGenIDs(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		<-userIDs
@@ -1,9 +1,43 @@
 package ircd
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"strings"
+	"unicode/utf8"
 )
 
+// Limits on the lengths of names and messages (advertised in RPL_ISUPPORT).
+const (
+	MaxNickLength    = 30
+	MaxChannelLength = 50
+	MaxTopicLength   = 390
+	MaxAwayLength    = 300
+	MaxLineLength    = 512
+)
+
+// randomID returns a random hex string encoding the given number of bytes,
+// for use as a message or batch ID.
+func randomID(bytes int) string {
+	buf := make([]byte, bytes)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// truncate shortens the string to at most the given number of bytes without
+// splitting a UTF-8 character.
+func truncate(str string, max int) string {
+	if len(str) <= max {
+		return str
+	}
+	for max > 0 && !utf8.RuneStart(str[max]) {
+		max--
+	}
+	return str[:max]
+}
+
 func isletter(r rune) bool {
 	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
 }
@@ -65,7 +99,7 @@ func ValidServerPrefix(pfx string) bool {
 }
 
 func ValidNick(str string) bool {
-	if len(str) == 0 {
+	if len(str) == 0 || len(str) > MaxNickLength {
 		return false
 	}
 	if isdigit(rune(str[0])) || str[0] == '-' {
@@ -79,10 +113,23 @@ func ValidNick(str string) bool {
 	return true
 }
 
-func ValidChannel(str string) bool {
+// ValidUser returns true if the string can be used as a username.
+func ValidUser(str string) bool {
 	if len(str) == 0 {
 		return false
 	}
+	for _, r := range str {
+		if !isletter(r) && !isdigit(r) && !isspecial(r) && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func ValidChannel(str string) bool {
+	if len(str) == 0 || len(str) > MaxChannelLength {
+		return false
+	}
 	if str[0] != '#' {
 		return false
 	}
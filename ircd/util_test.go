@@ -0,0 +1,27 @@
+package ircd
+
+import (
+	"testing"
+)
+
+var truncateTests = []struct {
+	Str  string
+	Max  int
+	Want string
+}{
+	{"topic", 10, "topic"},
+	{"topic", 5, "topic"},
+	{"topic", 3, "top"},
+	{"café", 5, "café"},
+	{"café", 4, "caf"},
+	{"日本", 5, "日"},
+	{"日本", 2, ""},
+}
+
+func TestTruncate(t *testing.T) {
+	for idx, test := range truncateTests {
+		if got, want := truncate(test.Str, test.Max), test.Want; got != want {
+			t.Errorf("#%d: truncate(%q, %d) = %q, want %q", idx, test.Str, test.Max, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ircd
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+var (
+	wallopshooks = []*Hook{
+		Register(CMD_WALLOPS, EMASK_USER|EMASK_SERVER, NArgs(1), Wallops),
+		Register(CMD_OPERWALL, EMASK_USER|EMASK_SERVER, NArgs(1), Wallops),
+	}
+)
+
+// Handle a WALLOPS or OPERWALL from a local operator or a linked server.
+// WALLOPS is delivered to users with +w and OPERWALL to operators.
+//
+//	WALLOPS :<text>
+//	OPERWALL :<text>
+func Wallops(hook string, msg *Message, ircd *IRCd) {
+	source := msg.SenderID
+	if len(msg.SenderID) == 3 {
+		source = msg.Prefix
+	} else if !GetUser(source).HasMode('o') {
+		ircd.ToClient <- NewNumeric(ERR_NOPRIVILEGES).Message(source)
+		return
+	}
+
+	text, mode := msg.Args[0], 'w'
+	if hook == CMD_OPERWALL {
+		text, mode = "OPERWALL - "+text, 'o'
+	}
+
+	local := []string{}
+	for uid := range UserIter() {
+		if uid[:3] == Config.SID && GetUser(uid).HasMode(mode) {
+			local = append(local, uid)
+		}
+	}
+	if len(local) > 0 {
+		prefix := source
+		if len(source) == 3 {
+			prefix = sourceName(source)
+		}
+		ircd.ToClient <- &Message{
+			Prefix:  prefix,
+			Command: CMD_WALLOPS,
+			Args: []string{
+				text,
+			},
+			DestIDs: local,
+		}
+	}
+
+	for sid := range ServerIter() {
+		if sid != msg.SenderID {
+			ircd.ToServer <- &Message{
+				Prefix:  source,
+				Command: hook,
+				Args: []string{
+					msg.Args[0],
+				},
+				DestIDs: []string{sid},
+			}
+		}
+	}
+}
+
+// isMassTarget returns true if the message target is a server mask ($mask)
+// or a host mask (#mask containing a wildcard).
+func isMassTarget(target string) bool {
+	switch {
+	case strings.HasPrefix(target, "$"):
+		return true
+	case strings.HasPrefix(target, "#"):
+		return strings.ContainsAny(target, "*?")
+	}
+	return false
+}
+
+// checkMassTarget returns an error if the mask of a mass message is too
+// broad: it must end in a top-level domain without wildcards.
+func checkMassTarget(target string) error {
+	dot := strings.LastIndex(target, ".")
+	if dot < 0 {
+		return NewNumeric(ERR_NOTOPLEVEL, target)
+	}
+	if strings.ContainsAny(target[dot+1:], "*?") {
+		return NewNumeric(ERR_WILDTOPLEVEL, target)
+	}
+	return nil
+}
+
+// massMessage delivers a PRIVMSG or NOTICE sent to a server or host mask to
+// the matching local users and passes it on to the other servers, which
+// deliver it to their own users.  The link is the server it came from, if
+// any.
+func massMessage(hook, sender, target, text, link string, ircd *IRCd) {
+	mask := ToLower(target[1:])
+	local := []string{}
+	for uid := range UserIter() {
+		if uid == sender || uid[:3] != Config.SID {
+			continue
+		}
+		if _, _, _, typ, ok := GetUserInfo(uid); !ok || typ != RegisteredAsUser {
+			continue
+		}
+		name := Config.Name
+		if target[0] == '#' {
+			name = GetUser(uid).Host()
+		}
+		if match, _ := filepath.Match(mask, ToLower(name)); match {
+			local = append(local, uid)
+		}
+	}
+	if len(local) > 0 {
+		ircd.ToClient <- &Message{
+			Prefix:  sender,
+			Command: hook,
+			Args: []string{
+				target,
+				text,
+			},
+			DestIDs: local,
+		}
+	}
+
+	for sid := range ServerIter() {
+		if sid != link {
+			ircd.ToServer <- &Message{
+				Prefix:  sender,
+				Command: hook,
+				Args: []string{
+					target,
+					text,
+				},
+				DestIDs: []string{sid},
+			}
+		}
+	}
+}
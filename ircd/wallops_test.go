@@ -0,0 +1,36 @@
+package ircd
+
+import (
+	"testing"
+)
+
+var massTargetTests = []struct {
+	Target string
+	Mass   bool
+	Error  string
+}{
+	{"#channel", false, ""},
+	{"nick", false, ""},
+	{"$*.example.com", true, ""},
+	{"#*.example.com", true, ""},
+	{"$*", true, ERR_NOTOPLEVEL},
+	{"#*.example.c?m", true, ERR_WILDTOPLEVEL},
+}
+
+func TestMassTarget(t *testing.T) {
+	for idx, test := range massTargetTests {
+		if got, want := isMassTarget(test.Target), test.Mass; got != want {
+			t.Errorf("#%d: isMassTarget(%q) = %v, want %v", idx, test.Target, got, want)
+		}
+		if !test.Mass {
+			continue
+		}
+		got := ""
+		if num, ok := checkMassTarget(test.Target).(*Numeric); ok {
+			got = num.num
+		}
+		if want := test.Error; got != want {
+			t.Errorf("#%d: checkMassTarget(%q) = %q, want %q", idx, test.Target, got, want)
+		}
+	}
+}
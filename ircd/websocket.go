@@ -0,0 +1,412 @@
+package ircd
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket clients send and receive one line per message, without the
+// trailing CR LF, in text or binary messages depending on the subprotocol
+// they chose (text if they chose neither).  Text messages which are not
+// valid UTF-8 are fixed before they are sent.
+const (
+	wsTextProtocol   = "text.ircv3.net"
+	wsBinaryProtocol = "binary.ircv3.net"
+)
+
+const (
+	// The GUID which is hashed with the key to accept a handshake.
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// How long a client has to finish the handshake.
+	wsHandshakeTimeout = 10 * time.Second
+
+	// The longest message a client may send (a line with tags).
+	wsMaxMessage = 8191 + MaxLineLength
+)
+
+// WebSocket frame opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// parseNetworks parses the IP addresses and CIDR ranges.
+func parseNetworks(specs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(specs))
+	for _, spec := range specs {
+		if ip := net.ParseIP(spec); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{
+				IP:   ip,
+				Mask: net.CIDRMask(bits, bits),
+			})
+			continue
+		}
+		_, network, err := net.ParseCIDR(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: must be an IP address or CIDR range", spec)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// wsAccept returns the Sec-WebSocket-Accept for the Sec-WebSocket-Key.
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerTokens returns the comma-separated tokens in the header values.
+func headerTokens(values []string) []string {
+	tokens := []string{}
+	for _, value := range values {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); len(token) > 0 {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+// headerHas returns true if the header contains the token (ignoring case).
+func headerHas(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h.Values(name)) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// A wsUpgrader turns connections to a WebSocket port into WebSocket
+// connections.
+type wsUpgrader struct {
+	origins []string
+	proxies []*net.IPNet
+}
+
+// allowOrigin returns true if a browser may connect from the origin.
+// Clients which are not browsers do not send an origin, and are always
+// allowed.
+func (w *wsUpgrader) allowOrigin(origin string) bool {
+	if len(w.origins) == 0 || len(origin) == 0 {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, glob := range w.origins {
+		if match, _ := filepath.Match(strings.ToLower(glob), origin); match {
+			return true
+		}
+	}
+	return false
+}
+
+// trusted returns true if the address is one of the trusted proxies.
+func (w *wsUpgrader) trusted(ip net.IP) bool {
+	for _, network := range w.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr returns the address of the client.  This is the address of the
+// connection unless it is from a trusted proxy, in which case it is the last
+// address in X-Forwarded-For which is not a trusted proxy.
+func (w *wsUpgrader) clientAddr(addr net.Addr, forwarded []string) net.Addr {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok || !w.trusted(tcp.IP) {
+		return addr
+	}
+	hops := headerTokens(forwarded)
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			break
+		}
+		addr = &net.TCPAddr{IP: ip}
+		if !w.trusted(ip) {
+			break
+		}
+	}
+	return addr
+}
+
+// upgrade reads the client's handshake from the connection and, if it is
+// acceptable, completes it and returns the WebSocket connection.
+func (w *wsUpgrader) upgrade(nc net.Conn) (net.Conn, error) {
+	nc.SetDeadline(time.Now().Add(wsHandshakeTimeout))
+	r := bufio.NewReader(nc)
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	refuse := func(status int, reason string) (net.Conn, error) {
+		fmt.Fprintf(nc, "HTTP/1.1 %d %s\r\nSec-WebSocket-Version: 13\r\nContent-Length: 0\r\nConnection: close\r\n\r\n",
+			status, http.StatusText(status))
+		return nil, errors.New("WebSocket handshake refused: " + reason)
+	}
+
+	key := req.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case req.Method != "GET":
+		return refuse(http.StatusMethodNotAllowed, "method "+req.Method)
+	case !headerHas(req.Header, "Connection", "upgrade"), !headerHas(req.Header, "Upgrade", "websocket"):
+		return refuse(http.StatusBadRequest, "not a WebSocket upgrade")
+	case req.Header.Get("Sec-WebSocket-Version") != "13":
+		return refuse(http.StatusUpgradeRequired, "unsupported version")
+	case len(key) == 0:
+		return refuse(http.StatusBadRequest, "no key")
+	case !w.allowOrigin(req.Header.Get("Origin")):
+		return refuse(http.StatusForbidden, "origin "+req.Header.Get("Origin"))
+	}
+
+	protocol := ""
+	for _, p := range headerTokens(req.Header.Values("Sec-WebSocket-Protocol")) {
+		if p == wsTextProtocol || p == wsBinaryProtocol {
+			protocol = p
+			break
+		}
+	}
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n"
+	if len(protocol) > 0 {
+		resp += "Sec-WebSocket-Protocol: " + protocol + "\r\n"
+	}
+	if _, err := io.WriteString(nc, resp+"\r\n"); err != nil {
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+
+	return &wsConn{
+		Conn:   nc,
+		reader: r,
+		addr:   w.clientAddr(nc.RemoteAddr(), req.Header.Values("X-Forwarded-For")),
+		binary: protocol == wsBinaryProtocol,
+		wmutex: new(sync.Mutex),
+	}, nil
+}
+
+// A wsConn is a WebSocket connection.  It is read and written like a raw
+// connection: each message read is followed by a newline, and each line
+// written is sent as a message.
+type wsConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	addr    net.Addr
+	binary  bool
+	pending []byte // the rest of the message being read
+
+	wmutex *sync.Mutex // held while writing a frame
+	closed bool        // a close frame has been sent
+}
+
+// RemoteAddr returns the address of the client, which may have been
+// forwarded by a trusted proxy.
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// Read reads from the messages sent by the client.
+func (c *wsConn) Read(b []byte) (int, error) {
+	if len(c.pending) == 0 {
+		msg, err := c.readMessage()
+		if err != nil {
+			return 0, err
+		}
+		c.pending = append(msg, '\n')
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readMessage reads the next text or binary message, answering any control
+// frames which come first.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			code := []byte{}
+			if len(payload) >= 2 {
+				code = payload[:2]
+			}
+			c.writeClose(code)
+			return nil, io.EOF
+		case wsText, wsBinary:
+			if started {
+				return nil, errors.New("WebSocket message interrupted")
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				return nil, errors.New("WebSocket continuation without a message")
+			}
+		default:
+			return nil, fmt.Errorf("WebSocket opcode %#x unknown", op)
+		}
+
+		if len(msg)+len(payload) > wsMaxMessage {
+			return nil, errors.New("WebSocket message too long")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads a frame from the client and unmasks its payload.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin, op = header[0]&0x80 != 0, header[0]&0x0F
+	if header[0]&0x70 != 0 {
+		return fin, op, nil, errors.New("WebSocket frame has reserved bits set")
+	}
+	if header[1]&0x80 == 0 {
+		return fin, op, nil, errors.New("WebSocket frame from client is not masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= wsClose && (length > 125 || !fin) {
+		return fin, op, nil, errors.New("WebSocket control frame is invalid")
+	}
+	if length > wsMaxMessage {
+		return fin, op, nil, errors.New("WebSocket message too long")
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// writeFrame sends a frame (which is never fragmented) to the client.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+	if c.closed {
+		return errors.New("WebSocket connection closed")
+	}
+	return c.writeFrameLocked(op, payload)
+}
+
+// writeFrameLocked is writeFrame for callers which hold wmutex.
+func (c *wsConn) writeFrameLocked(op byte, payload []byte) error {
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|op)
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 126, byte(length>>8), byte(length))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// writeClose sends a close frame with the status code (if any), unless one
+// has already been sent.
+func (c *wsConn) writeClose(code []byte) {
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.writeFrameLocked(wsClose, code)
+	c.closed = true
+}
+
+// Write sends each line as a message.
+func (c *wsConn) Write(b []byte) (int, error) {
+	op := byte(wsText)
+	if c.binary {
+		op = wsBinary
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		if !c.binary {
+			line = strings.ToValidUTF8(line, "\uFFFD")
+		}
+		if err := c.writeFrame(op, []byte(line)); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Close tells the client the connection is closing, and closes it.
+func (c *wsConn) Close() error {
+	c.writeClose([]byte{0x03, 0xE8}) // 1000: normal closure
+	return c.Conn.Close()
+}
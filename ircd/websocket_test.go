@@ -0,0 +1,165 @@
+package ircd
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestWebSocketAccept(t *testing.T) {
+	// The example from RFC 6455
+	if got, want := wsAccept("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("wsAccept = %q, want %q", got, want)
+	}
+}
+
+var clientAddrTests = []struct {
+	Remote    string
+	Forwarded []string
+	Expect    string
+}{
+	{
+		Remote:    "192.0.2.1",
+		Forwarded: []string{"198.51.100.7"},
+		Expect:    "192.0.2.1",
+	},
+	{
+		Remote: "127.0.0.1",
+		Expect: "127.0.0.1",
+	},
+	{
+		Remote:    "127.0.0.1",
+		Forwarded: []string{"198.51.100.7"},
+		Expect:    "198.51.100.7",
+	},
+	{
+		Remote:    "127.0.0.1",
+		Forwarded: []string{"203.0.113.9, 198.51.100.7", "10.1.2.3"},
+		Expect:    "198.51.100.7",
+	},
+	{
+		Remote:    "127.0.0.1",
+		Forwarded: []string{"2001:db8::1"},
+		Expect:    "2001:db8::1",
+	},
+	{
+		Remote:    "127.0.0.1",
+		Forwarded: []string{"bogus, 10.1.2.3"},
+		Expect:    "10.1.2.3",
+	},
+}
+
+func TestWebSocketClientAddr(t *testing.T) {
+	proxies, err := parseNetworks([]string{"127.0.0.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("parseNetworks: %s", err)
+	}
+	w := &wsUpgrader{proxies: proxies}
+	for i, test := range clientAddrTests {
+		addr := &net.TCPAddr{IP: net.ParseIP(test.Remote), Port: 1234}
+		got := w.clientAddr(addr, test.Forwarded).(*net.TCPAddr)
+		if !got.IP.Equal(net.ParseIP(test.Expect)) {
+			t.Errorf("#%d: clientAddr = %s, want %s", i, got.IP, test.Expect)
+		}
+	}
+
+	if _, err := parseNetworks([]string{"localhost"}); err == nil {
+		t.Errorf("parseNetworks(localhost) succeeded, want error")
+	}
+}
+
+// wsHandshake dials the port and sends a WebSocket handshake with the
+// headers.
+func wsHandshake(t *testing.T, addr string, headers ...string) (net.Conn, *bufio.Reader, *http.Response) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %s", err)
+	}
+	req := "GET / HTTP/1.1\r\n" +
+		"Host: localhost\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n"
+	for _, header := range headers {
+		req += header + "\r\n"
+	}
+	io.WriteString(c, req+"\r\n")
+	r := bufio.NewReader(c)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse: %s", err)
+	}
+	return c, r, resp
+}
+
+func TestAddWebSocketPort(t *testing.T) {
+	l := NewListener()
+	defer l.Close()
+	l.AddWebSocketPort(56563, nil, []string{"https://*.example.com"}, nil)
+
+	c, _, resp := wsHandshake(t, "localhost:56563", "Origin: https://evil.example.org")
+	c.Close()
+	if got, want := resp.StatusCode, http.StatusForbidden; got != want {
+		t.Errorf("status from disallowed origin = %d, want %d", got, want)
+	}
+
+	c, r, resp := wsHandshake(t, "localhost:56563",
+		"Origin: https://irc.example.com",
+		"Sec-WebSocket-Protocol: chat, binary.ircv3.net, text.ircv3.net")
+	defer c.Close()
+	if got, want := resp.StatusCode, http.StatusSwitchingProtocols; got != want {
+		t.Fatalf("status = %d, want %d", got, want)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("Sec-WebSocket-Accept = %q, want %q", got, want)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Protocol"), wsBinaryProtocol; got != want {
+		t.Errorf("Sec-WebSocket-Protocol = %q, want %q", got, want)
+	}
+
+	conn := <-l.Incoming
+	if got, want := conn.IP(), "127.0.0.1"; got != want {
+		t.Errorf("IP() = %q, want %q", got, want)
+	}
+	if !conn.WebSocket() {
+		t.Errorf("WebSocket() = false, want true")
+	}
+
+	// A masked message, sent in two fragments
+	mask := []byte{1, 2, 3, 4}
+	frame := func(header byte, payload string) []byte {
+		b := append([]byte{header, 0x80 | byte(len(payload))}, mask...)
+		for i := range payload {
+			b = append(b, payload[i]^mask[i%4])
+		}
+		return b
+	}
+	c.Write(append(frame(wsText, "NICK "), frame(0x80|wsContinuation, "test")...))
+
+	messages := make(chan *Message)
+	conn.Subscribe(messages)
+	if got, want := (<-messages).String(), "NICK test"; got != want {
+		t.Errorf("read %q, want %q", got, want)
+	}
+
+	ping := &Message{
+		Command: CMD_PING,
+		Args:    []string{"test"},
+	}
+	conn.WriteMessage(ping)
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatalf("reading frame: %s", err)
+	}
+	payload := make([]byte, header[1])
+	io.ReadFull(r, payload)
+	if got, want := header[0], byte(0x80|wsBinary); got != want {
+		t.Errorf("frame header = %#x, want %#x", got, want)
+	}
+	if got, want := string(payload), ping.String(); got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,342 @@
+package ircd
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	whohooks = append([]*Hook{
+		Register(CMD_WHO, EMASK_USER, OptArgs(0, 2), Who),
+		Register(CMD_WHOIS, EMASK_USER, OptArgs(1, 1), Whois),
+		Register(CMD_WHOIS, EMASK_SERVER, NArgs(2), SWhois),
+		Register(CMD_WHOWAS, EMASK_USER, OptArgs(1, 2), Whowas),
+	}, relayNumerics(
+		RPL_WHOISUSER,
+		RPL_AWAY,
+		RPL_WHOISSERVER,
+		RPL_WHOISOPERATOR,
+		RPL_WHOISIDLE,
+		RPL_WHOISCHANNELS,
+		RPL_WHOISSECURE,
+		RPL_WHOISACCOUNT,
+		RPL_WHOISCERTFP,
+		RPL_ENDOFWHOIS,
+		ERR_NOSUCHNICK,
+	)...)
+)
+
+// The maximum length of the channel list in one RPL_WHOISCHANNELS.
+const whoisChannelsLength = 400
+
+// sharesChannel returns true if the users are members of a common channel.
+func sharesChannel(uid, other string) bool {
+	for _, channel := range UserChannels(uid) {
+		if channel.OnChan(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchUser returns true if the glob matches the user's nick, username,
+// visible hostname, server name, or real name.
+func matchUser(glob, uid string) bool {
+	nick, user, name, _, _ := GetUserInfo(uid)
+	glob = ToLower(glob)
+	for _, field := range []string{
+		nick,
+		user,
+		GetUser(uid).VisibleHost(),
+		sourceName(uid[:3]),
+		name,
+	} {
+		if match, _ := filepath.Match(glob, ToLower(field)); match {
+			return true
+		}
+	}
+	return false
+}
+
+// whoReply returns an RPL_WHOREPLY for the user, who is listed as a member of
+// the channel (if one is given) with the given status prefixes.
+func whoReply(uid, channel, status string) *Message {
+	u := GetUser(uid)
+	_, user, name, _ := u.Info()
+	flags := "H"
+	if len(u.Away()) > 0 {
+		flags = "G"
+	}
+	if u.HasMode('o') {
+		flags += "*"
+	}
+	flags += status
+	if len(channel) == 0 {
+		channel = "*"
+	}
+	return &Message{
+		Command: RPL_WHOREPLY,
+		Args: []string{
+			"*",
+			channel,
+			user,
+			u.VisibleHost(),
+			sourceName(uid[:3]),
+			uid,
+			flags,
+			strconv.Itoa(u.Hops()) + " " + name,
+		},
+	}
+}
+
+// Handle a WHO from a local client.  Invisible users are only listed if they
+// share a channel with the sender, and members of secret and private channels
+// are only listed to other members.
+//
+//	WHO [<channel>|<mask> [o]]
+func Who(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	mask := "*"
+	if len(msg.Args) > 0 && msg.Args[0] != "0" {
+		mask = msg.Args[0]
+	}
+	opersOnly := len(msg.Args) > 1 && msg.Args[1] == "o"
+
+	if ValidChannel(mask) {
+		if channel, err := GetChannel(mask, false); err == nil {
+			member := channel.OnChan(uid)
+			hidden := channel.HasMode('s') || channel.HasMode('p')
+			for _, id := range channel.UserIDs() {
+				u := GetUser(id)
+				switch {
+				case opersOnly && !u.HasMode('o'):
+					continue
+				case member:
+				case hidden || u.HasMode('i'):
+					continue
+				}
+				reply := whoReply(id, channel.Name(), visiblePrefix(uid, channel.Status(id)))
+				reply.DestIDs = []string{uid}
+				ircd.ToClient <- reply
+			}
+		}
+	} else {
+		for id := range UserIter() {
+			_, _, _, typ, ok := GetUserInfo(id)
+			if !ok || typ != RegisteredAsUser {
+				continue
+			}
+			u := GetUser(id)
+			if opersOnly && !u.HasMode('o') {
+				continue
+			}
+			if id != uid && u.HasMode('i') && !sharesChannel(uid, id) {
+				continue
+			}
+			if !matchUser(mask, id) {
+				continue
+			}
+			reply := whoReply(id, "", "")
+			reply.DestIDs = []string{uid}
+			ircd.ToClient <- reply
+		}
+	}
+
+	ircd.ToClient <- NewNumeric(RPL_ENDOFWHO, mask).Message(uid)
+}
+
+// Handle a WHOIS from a local client.  If a target server (or a nick, meaning
+// the nick's server) is given, the WHOIS is answered by that server so that
+// it can include the idle time.
+//
+//	WHOIS [<target>] <nick>{,<nick>}
+func Whois(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	nicks := msg.Args[len(msg.Args)-1]
+
+	if len(msg.Args) == 2 {
+		target := msg.Args[0]
+		hunted, err := GetID(target)
+		if err != nil {
+			sid, ok := ServerByName(target)
+			if !ok {
+				ircd.ToClient <- NewNumeric(ERR_NOSUCHSERVER, target).Message(uid)
+				return
+			}
+			hunted = sid
+		}
+		if hunted[:3] != Config.SID {
+			for sid := range IterFor([]string{hunted}, "") {
+				ircd.ToServer <- &Message{
+					Prefix:  uid,
+					Command: CMD_WHOIS,
+					Args: []string{
+						hunted,
+						nicks,
+					},
+					DestIDs: []string{sid},
+				}
+			}
+			return
+		}
+	}
+
+	sendWhois(uid, nicks, ircd)
+}
+
+// Handle a WHOIS from a linked server.
+//
+//	:<uid> WHOIS <uid|sid> :<nick>{,<nick>}
+func SWhois(hook string, msg *Message, ircd *IRCd) {
+	hunted := msg.Args[0]
+	if hunted[:3] == Config.SID {
+		sendWhois(msg.Prefix, msg.Args[1], ircd)
+		return
+	}
+	for sid := range IterFor([]string{hunted}, msg.SenderID) {
+		fmsg := msg.Dup()
+		fmsg.DestIDs = []string{sid}
+		ircd.ToServer <- fmsg
+	}
+}
+
+// sendWhois sends the WHOIS replies for each of the nicks to the (local or
+// remote) user.
+func sendWhois(uid, nicks string, ircd *IRCd) {
+	for _, nick := range strings.Split(nicks, ",") {
+		id, err := GetID(nick)
+		if num, ok := err.(*Numeric); ok {
+			sendToUser(uid, num.Message(), ircd)
+			continue
+		}
+		whoisUser(uid, id, ircd)
+	}
+	sendToUser(uid, NewNumeric(RPL_ENDOFWHOIS, nicks).Message(), ircd)
+}
+
+// whoisChannels returns the channels of the target (with status prefixes)
+// which the user may see.  Secret and private channels and the channels of
+// invisible users are only shown if the user is also a member.
+func whoisChannels(uid, target string) []string {
+	invisible := GetUser(target).HasMode('i') && uid != target
+	names := []string{}
+	for _, channel := range UserChannels(target) {
+		hidden := invisible || channel.HasMode('s') || channel.HasMode('p')
+		if hidden && !channel.OnChan(uid) {
+			continue
+		}
+		names = append(names, channel.Status(target)+channel.Name())
+	}
+	return names
+}
+
+// whoisUser sends the WHOIS replies (except RPL_ENDOFWHOIS) for the target to
+// the user.
+func whoisUser(uid, target string, ircd *IRCd) {
+	u := GetUser(target)
+	_, user, name, _ := u.Info()
+
+	sendToUser(uid, &Message{
+		Command: RPL_WHOISUSER,
+		Args: []string{
+			"*",
+			target,
+			user,
+			u.VisibleHost(),
+			"*",
+			name,
+		},
+	}, ircd)
+
+	names := whoisChannels(uid, target)
+	for len(names) > 0 {
+		line, n := "", 0
+		for ; n < len(names); n++ {
+			if n > 0 && len(line)+len(names[n]) >= whoisChannelsLength {
+				break
+			}
+			line += names[n] + " "
+		}
+		msg := NewNumeric(RPL_WHOISCHANNELS, target).Message()
+		msg.Args[2] = strings.TrimSpace(line)
+		sendToUser(uid, msg, ircd)
+		names = names[n:]
+	}
+
+	sid := target[:3]
+	desc := Config.Network.Description
+	if s := GetServer(sid, false); s != nil && sid != Config.SID {
+		desc = s.Description()
+	}
+	msg := NewNumeric(RPL_WHOISSERVER, target, sourceName(sid)).Message()
+	msg.Args[3] = desc
+	sendToUser(uid, msg, ircd)
+
+	if away := u.Away(); len(away) > 0 {
+		msg := NewNumeric(RPL_AWAY, target).Message()
+		msg.Args[2] = away
+		sendToUser(uid, msg, ircd)
+	}
+	if u.HasMode('o') {
+		sendToUser(uid, NewNumeric(RPL_WHOISOPERATOR, target).Message(), ircd)
+	}
+	if u.HasMode('Z') {
+		sendToUser(uid, NewNumeric(RPL_WHOISSECURE, target).Message(), ircd)
+	}
+	if account := u.Account(); len(account) > 0 {
+		sendToUser(uid, NewNumeric(RPL_WHOISACCOUNT, target, account).Message(), ircd)
+	}
+
+	// Idle times and certificate fingerprints are only known locally
+	if sid != Config.SID {
+		return
+	}
+	if fp := u.Fingerprint(); len(fp) > 0 && (uid == target || GetUser(uid).HasMode('o')) {
+		msg := NewNumeric(RPL_WHOISCERTFP, target).Message()
+		msg.Args[2] = "has client certificate fingerprint " + fp
+		sendToUser(uid, msg, ircd)
+	}
+	sendToUser(uid, NewNumeric(RPL_WHOISIDLE,
+		target,
+		strconv.Itoa(int(u.Idle().Seconds())),
+		strconv.FormatInt(u.Signon().Unix(), 10),
+	).Message(), ircd)
+}
+
+// Handle a WHOWAS from a local client.
+//
+//	WHOWAS <nick>{,<nick>} [<count> [<target>]]
+func Whowas(hook string, msg *Message, ircd *IRCd) {
+	uid := msg.SenderID
+	count := 0
+	if len(msg.Args) > 1 {
+		count, _ = strconv.Atoi(msg.Args[1])
+	}
+
+	for _, nick := range strings.Split(msg.Args[0], ",") {
+		entries := WhowasHistory(nick, count)
+		if len(entries) == 0 {
+			ircd.ToClient <- NewNumeric(ERR_WASNOSUCHNICK, nick).Message(uid)
+		}
+		for _, entry := range entries {
+			ircd.ToClient <- &Message{
+				Command: RPL_WHOWASUSER,
+				Args: []string{
+					"*",
+					entry.Nick,
+					entry.User,
+					entry.Host,
+					"*",
+					entry.Name,
+				},
+				DestIDs: []string{uid},
+			}
+			msg := NewNumeric(RPL_WHOISSERVER, entry.Nick, entry.Server).Message(uid)
+			msg.Args[3] = entry.Time.Format(time.RFC1123)
+			ircd.ToClient <- msg
+		}
+		ircd.ToClient <- NewNumeric(RPL_ENDOFWHOWAS, nick).Message(uid)
+	}
+}
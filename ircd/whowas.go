@@ -0,0 +1,88 @@
+package ircd
+
+import (
+	"sync"
+	"time"
+)
+
+// The number of entries kept in the WHOWAS history.
+var WhowasSize = 1024
+
+// A WhowasEntry records a nick which is no longer in use.
+type WhowasEntry struct {
+	Nick   string
+	User   string
+	Host   string
+	Name   string
+	Server string
+	Time   time.Time
+}
+
+// The WHOWAS history is a ring buffer; whowasNext is the index of the slot
+// which will be overwritten next.
+var (
+	whowasMutex   = new(sync.RWMutex)
+	whowasEntries []WhowasEntry
+	whowasNext    int
+)
+
+// AddWhowas records the user's current nick in the WHOWAS history.  It should
+// be called before the user quits.
+func AddWhowas(uid string) {
+	if entry, ok := whowasEntry(uid); ok {
+		addWhowas(entry)
+	}
+}
+
+// whowasEntry returns a WHOWAS entry for the user's current nick.
+func whowasEntry(uid string) (entry WhowasEntry, ok bool) {
+	nick, user, name, _, ok := GetUserInfo(uid)
+	if !ok || nick == "*" {
+		return entry, false
+	}
+	return WhowasEntry{
+		Nick:   nick,
+		User:   user,
+		Host:   GetUser(uid).VisibleHost(),
+		Name:   name,
+		Server: sourceName(uid[:3]),
+		Time:   time.Now(),
+	}, true
+}
+
+// addWhowas adds the entry to the WHOWAS history, replacing the oldest entry
+// if the history is full.
+func addWhowas(entry WhowasEntry) {
+	whowasMutex.Lock()
+	defer whowasMutex.Unlock()
+
+	if len(whowasEntries) < WhowasSize {
+		whowasEntries = append(whowasEntries, entry)
+		return
+	}
+	whowasEntries[whowasNext] = entry
+	whowasNext = (whowasNext + 1) % len(whowasEntries)
+}
+
+// WhowasHistory returns up to count entries for the nick from the WHOWAS history,
+// most recent first.  If count is not positive, all entries are returned.
+func WhowasHistory(nick string, count int) []WhowasEntry {
+	whowasMutex.RLock()
+	defer whowasMutex.RUnlock()
+
+	lownick := ToLower(nick)
+	found := []WhowasEntry{}
+	n := len(whowasEntries)
+	for i := 0; i < n; i++ {
+		// Walk backwards from the most recent entry
+		entry := whowasEntries[(whowasNext-1-i+2*n)%n]
+		if ToLower(entry.Nick) != lownick {
+			continue
+		}
+		found = append(found, entry)
+		if len(found) == count {
+			break
+		}
+	}
+	return found
+}
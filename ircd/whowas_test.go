@@ -0,0 +1,62 @@
+package ircd
+
+import (
+	"testing"
+)
+
+func TestWhowas(t *testing.T) {
+	defer func(size int) {
+		WhowasSize = size
+		whowasEntries, whowasNext = nil, 0
+	}(WhowasSize)
+	WhowasSize = 4
+	whowasEntries, whowasNext = nil, 0
+
+	add := func(nick, user string) {
+		addWhowas(WhowasEntry{Nick: nick, User: user})
+	}
+
+	tests := []struct {
+		Add   [][2]string
+		Nick  string
+		Count int
+		Users []string
+	}{
+		{
+			Add:   [][2]string{{"Foo", "a"}, {"bar", "b"}, {"foo", "c"}},
+			Nick:  "FOO",
+			Users: []string{"c", "a"},
+		},
+		{
+			Nick:  "foo",
+			Count: 1,
+			Users: []string{"c"},
+		},
+		{
+			// The oldest entries are replaced once the history is full
+			Add:   [][2]string{{"baz", "d"}, {"foo", "e"}, {"foo", "f"}},
+			Nick:  "foo",
+			Users: []string{"f", "e", "c"},
+		},
+		{
+			Nick:  "bar",
+			Users: []string{},
+		},
+	}
+
+	for idx, test := range tests {
+		for _, entry := range test.Add {
+			add(entry[0], entry[1])
+		}
+		found := WhowasHistory(test.Nick, test.Count)
+		if got, want := len(found), len(test.Users); got != want {
+			t.Errorf("#%d: len(WhowasHistory(%q, %d)) = %d, want %d", idx, test.Nick, test.Count, got, want)
+			continue
+		}
+		for i, entry := range found {
+			if got, want := entry.User, test.Users[i]; got != want {
+				t.Errorf("#%d: WhowasHistory(%q, %d)[%d].User = %q, want %q", idx, test.Nick, test.Count, i, got, want)
+			}
+		}
+	}
+}
@@ -52,12 +52,17 @@ func main() {
 				log.Printf("Overwriting numeric %s (%s) with %s", o, numeric, n)
 
 				// Remove the old text mapping
-				delete(name2text, o)
+				if o != n {
+					delete(name2text, o)
+				}
+			} else {
+				numerics = append(numerics, numeric)
 			}
 
-			numerics = append(numerics, numeric)
+			if _, redefine := name2text[name]; !redefine {
+				names = append(names, name)
+			}
 			numeric2name[numeric] = name
-			names = append(names, name)
 			name2text[name] = text
 		}
 	}